@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/outline"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/google/uuid"
+)
+
+// blogTitle is the title of the document blog posts are migrated below when
+// they share the collection with the pages.
+const blogTitle = "Blog"
+
+// blogYear is the blog posts published in one year, grouped by month.
+type blogYear struct {
+	year   int
+	months []blogMonth
+}
+
+// blogMonth is the blog posts published in one month.
+type blogMonth struct {
+	year  int
+	month int
+	posts []*confluence.BlogPost
+}
+
+// title is the title of the document, or the name of the folder, holding
+// the posts of the month.
+func (m blogMonth) title() string {
+	return fmt.Sprintf("%d-%02d", m.year, m.month)
+}
+
+// blogArchive groups posts by the year and month they were published in,
+// newest first.
+func blogArchive(posts []*confluence.BlogPost) []blogYear {
+	posts = slices.Clone(posts)
+	slices.SortStableFunc(posts, func(a, b *confluence.BlogPost) int {
+		return b.Published.Compare(a.Published)
+	})
+	var years []blogYear
+	for _, post := range posts {
+		year, month := post.Published.Year(), int(post.Published.Month())
+		if len(years) == 0 || years[len(years)-1].year != year {
+			years = append(years, blogYear{year: year})
+		}
+		months := &years[len(years)-1].months
+		if len(*months) == 0 || (*months)[len(*months)-1].month != month {
+			*months = append(*months, blogMonth{year: year, month: month})
+		}
+		(*months)[len(*months)-1].posts = append((*months)[len(*months)-1].posts, post)
+	}
+	return years
+}
+
+// blogPostURLs lists the relative URLs under which Confluence links to the
+// blog post: the pageId form and both title-encoded /display/ forms, which
+// include the publish date.
+func blogPostURLs(spaceKey string, post *confluence.BlogPost) []string {
+	date := post.Published.Format("2006/01/02")
+	encodedTitle := strings.ReplaceAll(post.Title, ":", "%3A")
+	return []string{
+		confluencePageURL(post.ID),
+		fmt.Sprintf(`/display/%s/%s/%s`, spaceKey, date, encodedTitle),
+		fmt.Sprintf(`/display/%s/%s/%s`, spaceKey, date, strings.ReplaceAll(encodedTitle, " ", "+")),
+	}
+}
+
+// publishedHeader returns the step adding the publish date of the blog post
+// to its document.
+func publishedHeader(post *confluence.BlogPost) transform.PublishedHeader {
+	return transform.PublishedHeader{Published: post.Published, Author: post.Author}
+}
+
+// migrateBlogPosts creates a document per year and month below a Blog
+// document, or at the top of the blog collection when one is set, and
+// migrates every post into the document of its month with migrate.
+func (m *Migrator) migrateBlogPosts(posts []*confluence.BlogPost, migrate func(m *Migrator, post *cf.Content, parentDocumentId string) error) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	// The posts are imported by a copy of the migrator whose collection is
+	// the blog collection; URL mappings go to the same map.
+	blogMigrator := *m
+	parentDocumentId := ""
+	if m.blogCollectionId != "" {
+		blogMigrator.collectionId = m.blogCollectionId
+	} else {
+		blogId, err := m.createContainerDocument(m.collectionId, blogTitle, m.parentDocumentId)
+		if err != nil {
+			return err
+		}
+		parentDocumentId = blogId
+	}
+
+	// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing keeps the newest first.
+	years := blogArchive(posts)
+	for i := len(years) - 1; i >= 0; i-- {
+		yearId, err := m.createContainerDocument(blogMigrator.collectionId, strconv.Itoa(years[i].year), parentDocumentId)
+		if err != nil {
+			return err
+		}
+		months := years[i].months
+		for j := len(months) - 1; j >= 0; j-- {
+			monthId, err := m.createContainerDocument(blogMigrator.collectionId, months[j].title(), yearId)
+			if err != nil {
+				return err
+			}
+			for k := len(months[j].posts) - 1; k >= 0; k-- {
+				if err := migrate(&blogMigrator, months[j].posts[k].Content, monthId); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// createContainerDocument creates an empty document titled title for other
+// documents to be placed below and returns its id.
+func (m *Migrator) createContainerDocument(collectionId, title, parentDocumentId string) (string, error) {
+	document, err := m.createDocument(collectionId, title, "", parentDocumentId)
+	if err != nil {
+		return "", err
+	}
+	return document.Id.String(), nil
+}
+
+// createDocument creates a published document titled title with the
+// Markdown text, or an empty one when text is "".
+func (m *Migrator) createDocument(collectionId, title, text, parentDocumentId string) (*outline.Document, error) {
+	createBody := outline.PostDocumentsCreateJSONRequestBody{
+		CollectionId: uuid.MustParse(collectionId),
+		Title:        title,
+	}
+	if text != "" {
+		createBody.Text = &text
+	}
+	if parentDocumentId != "" {
+		parentDocumentUuid := uuid.MustParse(parentDocumentId)
+		createBody.ParentDocumentId = &parentDocumentUuid
+	}
+	created, err := m.outlineClient.CreateDocument(createBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create document %s: %w", title, err)
+	}
+	if created.JSON200 == nil || created.JSON200.Data == nil {
+		return nil, fmt.Errorf("creating document %s failed: status %d body: %s", title, created.StatusCode(), string(created.Body))
+	}
+	m.logger.Info("Created document", "documentId", created.JSON200.Data.Id.String(), "documentTitle", title)
+	return created.JSON200.Data, nil
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+func testBlogPost(id, title string, published time.Time) *confluence.BlogPost {
+	return &confluence.BlogPost{Content: &cf.Content{ID: id, Type: "blogpost", Title: title}, Published: published}
+}
+
+func TestBlogArchive(t *testing.T) {
+	posts := []*confluence.BlogPost{
+		testBlogPost("1", "Kickoff", time.Date(2023, time.December, 4, 0, 0, 0, 0, time.UTC)),
+		testBlogPost("2", "Release 1.0", time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+		testBlogPost("3", "Retro", time.Date(2024, time.March, 28, 0, 0, 0, 0, time.UTC)),
+		testBlogPost("4", "Planning", time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)),
+	}
+
+	var got []string
+	for _, year := range blogArchive(posts) {
+		for _, month := range year.months {
+			for _, post := range month.posts {
+				got = append(got, month.title()+" "+post.Title)
+			}
+		}
+	}
+	want := []string{"2024-03 Retro", "2024-03 Release 1.0", "2024-01 Planning", "2023-12 Kickoff"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("blogArchive() = %q, want %q", got, want)
+	}
+}
+
+func TestBlogPostURLs(t *testing.T) {
+	post := testBlogPost("42", "Release: 1.0 is out", time.Date(2024, time.March, 5, 9, 0, 0, 0, time.UTC))
+	got := blogPostURLs("ENG", post)
+	want := []string{
+		"/pages/viewpage.action?pageId=42",
+		"/display/ENG/2024/03/05/Release%3A 1.0 is out",
+		"/display/ENG/2024/03/05/Release%3A+1.0+is+out",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("blogPostURLs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/oskarspakers/confluence-to-outline/outline"
+
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+)
+
+// attachmentURLRegex matches Outline attachment links in Markdown link and
+// image targets, in relative or absolute form, with the optional title that
+// follows them, such as the " =WxH" size of an image.
+var attachmentURLRegex = regexp.MustCompile(`\(((?:https?://[^\s()]+)?/api/attachments\.redirect\?id=[0-9a-fA-F-]+)(\s+"[^"]*")?\)`)
+
+type CollectionCopier struct {
+	sourceClient       *outline.OutlineExtendedClient
+	targetClient       *outline.OutlineExtendedClient
+	targetCollectionId uuid.UUID
+	urlMap             map[string]UrlMapEntry
+	attachmentMap      map[string]string
+	documents          []DocumentData
+	logger             *slog.Logger
+}
+
+// copyCollectionCmd represents the copy-collection command
+var copyCollectionCmd = &cobra.Command{
+	Use:   "copy-collection",
+	Short: "Copy an Outline collection to another Outline instance",
+	Long: `Reads the document tree of a collection on the source Outline instance
+(OUTLINE_SOURCE_BASE_URL, OUTLINE_SOURCE_API_TOKEN) and recreates it in a collection on the
+target instance (OUTLINE_BASE_URL, OUTLINE_API_TOKEN). Attachments are re-uploaded and links
+between documents of the collection are rewritten to the copies.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		lvl := new(slog.LevelVar)
+		levelString := cmd.Flag("log").Value.String()
+		lvl.UnmarshalText([]byte(levelString))
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: lvl,
+		}))
+
+		fatal := func(msg string, err error) {
+			if err != nil {
+				logger.Error(msg, "error", err)
+			} else {
+				logger.Error(msg)
+			}
+			os.Exit(1)
+		}
+
+		sourceCollectionId, err := cmd.Flags().GetString("from")
+		if err != nil {
+			fatal("Error getting --from flag", err)
+		}
+
+		targetCollectionId, err := cmd.Flags().GetString("to")
+		if err != nil {
+			fatal("Error getting --to flag", err)
+		}
+
+		sourceCollectionUuid, err := uuid.Parse(sourceCollectionId)
+		if err != nil {
+			fatal(fmt.Sprintf("invalid --from %q: must be a collection id", sourceCollectionId), nil)
+		}
+		targetCollectionUuid, err := uuid.Parse(targetCollectionId)
+		if err != nil {
+			fatal(fmt.Sprintf("invalid --to %q: must be a collection id", targetCollectionId), nil)
+		}
+
+		rateLimit, err := outlineRateLimitFromFlags(cmd)
+		if err != nil {
+			fatal(err.Error(), nil)
+		}
+
+		sourceClient, err := outline.GetClientFromEnv(logger, rateLimit, "OUTLINE_SOURCE_BASE_URL", "OUTLINE_SOURCE_API_TOKEN")
+		if err != nil {
+			fatal("Error creating source Outline client", err)
+		}
+
+		targetClient, err := outline.GetClient(logger, rateLimit)
+		if err != nil {
+			fatal("Error creating target Outline client", err)
+		}
+
+		copier := CollectionCopier{
+			sourceClient:       sourceClient,
+			targetClient:       targetClient,
+			targetCollectionId: targetCollectionUuid,
+			urlMap:             make(map[string]UrlMapEntry),
+			attachmentMap:      make(map[string]string),
+			logger:             logger,
+		}
+
+		logger.Info("Copying Outline collection", "sourceCollectionId", sourceCollectionId, "sourceUrl", sourceClient.GetBaseURL(), "targetCollectionId", targetCollectionId, "targetUrl", targetClient.GetBaseURL())
+
+		tree, err := sourceClient.Client.PostCollectionsDocumentsWithResponse(context.Background(), outline.PostCollectionsDocumentsJSONRequestBody{
+			Id: sourceCollectionUuid,
+		})
+		if err != nil {
+			fatal("Error getting source collection documents", err)
+		}
+		if tree.JSON200 == nil || tree.JSON200.Data == nil {
+			fatal(fmt.Sprintf("failed to get source collection documents (status %d): %s", tree.StatusCode(), string(tree.Body)), nil)
+		}
+
+		nodes := *tree.JSON200.Data
+		// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves the source order.
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if err := copier.copyDocumentRecurse(nodes[i], nil); err != nil {
+				fatal("Copy failed", err)
+			}
+		}
+		outputDataToJSON(copier.urlMap, "copyUrlMap")
+		copier.rewriteLinks()
+	},
+}
+
+func (c *CollectionCopier) copyDocumentRecurse(node outline.NavigationNode, parentDocumentId *uuid.UUID) error {
+	if node.Id == nil {
+		return nil
+	}
+	sourceId := node.Id.String()
+	info, err := c.sourceClient.Client.PostDocumentsInfoWithResponse(context.Background(), outline.PostDocumentsInfoJSONRequestBody{
+		Id: &sourceId,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get source document %s: %w", sourceId, err)
+	}
+	if info.JSON200 == nil || info.JSON200.Data == nil {
+		return fmt.Errorf("failed to get source document %s (status %d): %s", sourceId, info.StatusCode(), string(info.Body))
+	}
+	document := info.JSON200.Data
+
+	title := ""
+	if document.Title != nil {
+		title = *document.Title
+	}
+	text := ""
+	if document.Text != nil {
+		text = c.copyAttachments(*document.Text)
+	}
+
+	// Drafts of the source stay drafts.
+	publish := document.PublishedAt != nil
+	createBody := outline.PostDocumentsCreateJSONRequestBody{
+		CollectionId:     c.targetCollectionId,
+		Title:            title,
+		Text:             &text,
+		ParentDocumentId: parentDocumentId,
+		Publish:          &publish,
+	}
+	created, err := c.targetClient.CreateDocument(createBody)
+	if err != nil {
+		return fmt.Errorf("failed to create document %s (%s): %w", sourceId, title, err)
+	}
+	if created.JSON200 == nil || created.JSON200.Data == nil {
+		return fmt.Errorf("create failed for document %s (%s): status %d body: %s", sourceId, title, created.StatusCode(), string(created.Body))
+	}
+	createdDocumentId := created.JSON200.Data.Id.String()
+	c.logger.Info("Copied document", "sourceDocumentId", sourceId, "documentId", createdDocumentId, "documentTitle", title)
+
+	if node.Url != nil {
+		newUrl := fmt.Sprintf(`/doc/%s-%s`, slug.Make(title), *created.JSON200.Data.UrlId)
+		c.urlMap = updateUrlMap(c.urlMap, *node.Url, newUrl, createdDocumentId)
+	}
+	c.documents = append(c.documents, DocumentData{DocId: createdDocumentId, DocBody: text, Title: title})
+
+	if node.Children == nil {
+		return nil
+	}
+	children := *node.Children
+	// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves the source order.
+	for i := len(children) - 1; i >= 0; i-- {
+		if err := c.copyDocumentRecurse(children[i], created.JSON200.Data.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyAttachments downloads every attachment referenced by text from the
+// source instance, uploads it to the target instance and returns text with
+// the links replaced. Attachments referenced from several documents are only
+// copied once. Failed copies keep the source link and are logged.
+func (c *CollectionCopier) copyAttachments(text string) string {
+	return attachmentURLRegex.ReplaceAllStringFunc(text, func(match string) string {
+		submatches := attachmentURLRegex.FindStringSubmatch(match)
+		sourceURL, title := submatches[1], submatches[2]
+		if targetURL, ok := c.attachmentMap[sourceURL]; ok {
+			return "(" + targetURL + title + ")"
+		}
+
+		data, contentType, err := c.sourceClient.DownloadAttachment(sourceURL)
+		if err != nil {
+			c.logger.Warn("Failed to download attachment", "url", sourceURL, "error", err)
+			return match
+		}
+		targetURL, err := c.targetClient.UploadAttachment(data, attachmentFilename(sourceURL, contentType), contentType)
+		if err != nil {
+			c.logger.Warn("Failed to upload attachment", "url", sourceURL, "error", err)
+			return match
+		}
+		c.attachmentMap[sourceURL] = targetURL
+		return "(" + targetURL + title + ")"
+	})
+}
+
+// attachmentFilename derives a file name for an attachment from its id and
+// content type, since Outline attachment URLs carry no name.
+func attachmentFilename(attachmentURL string, contentType string) string {
+	name := "attachment"
+	if idx := strings.LastIndex(attachmentURL, "id="); idx != -1 {
+		name = attachmentURL[idx+len("id="):]
+	}
+	if extensions, err := mime.ExtensionsByType(contentType); err == nil && len(extensions) > 0 {
+		name += extensions[0]
+	}
+	return name
+}
+
+// rewriteLinks points links between documents of the copied collection to
+// their copies. Only documents whose text changes are updated.
+func (c *CollectionCopier) rewriteLinks() {
+	sourceHostname := strings.TrimSuffix(c.sourceClient.GetBaseURL(), "/api")
+	targetHostname := strings.TrimSuffix(c.targetClient.GetBaseURL(), "/api")
+	for _, documentData := range c.documents {
+		body := documentData.DocBody
+		for oldUrl, urlInfo := range c.urlMap {
+			body = rewriteConfluenceURL(oldUrl, urlInfo, body, sourceHostname, targetHostname)
+		}
+		if body == documentData.DocBody {
+			continue
+		}
+		documentData.DocBody = body
+		publish := true
+		done := true
+		resp, err := c.targetClient.Client.PostDocumentsUpdateWithResponse(context.Background(), outline.PostDocumentsUpdateJSONRequestBody{
+			Id:      documentData.DocId,
+			Title:   &documentData.Title,
+			Text:    &documentData.DocBody,
+			Publish: &publish,
+			Done:    &done,
+		})
+		if err != nil {
+			c.logger.Error("Failed to update Outline document", "documentId", documentData.DocId, "error", err)
+			continue
+		}
+		if resp.JSON200 == nil {
+			c.logger.Error("Failed to update Outline document", "documentId", documentData.DocId, "status", resp.StatusCode(), "body", string(resp.Body))
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(copyCollectionCmd)
+	copyCollectionCmd.PersistentFlags().String("from", "", "Collection id on the source Outline instance")
+	copyCollectionCmd.MarkPersistentFlagRequired("from")
+	copyCollectionCmd.PersistentFlags().String("to", "", "Collection id on the target Outline instance to copy documents into")
+	copyCollectionCmd.MarkPersistentFlagRequired("to")
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttachmentURLRegex(t *testing.T) {
+	body := "![](/api/attachments.redirect?id=0b7c1c5e-1f4f-4e4e-9d0a-1c2b3c4d5e6f) " +
+		"[spec.pdf](https://outline.example.com/api/attachments.redirect?id=aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee) " +
+		"![](/api/attachments.redirect?id=11111111-2222-3333-4444-555555555555 \" =640x480\") " +
+		"[doc](/doc/home-abc)"
+
+	var got []string
+	for _, match := range attachmentURLRegex.FindAllStringSubmatch(body, -1) {
+		got = append(got, match[1]+"|"+match[2])
+	}
+	want := []string{
+		"/api/attachments.redirect?id=0b7c1c5e-1f4f-4e4e-9d0a-1c2b3c4d5e6f|",
+		"https://outline.example.com/api/attachments.redirect?id=aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee|",
+		"/api/attachments.redirect?id=11111111-2222-3333-4444-555555555555| \" =640x480\"",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("attachment URLs = %v, want %v", got, want)
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	got := attachmentFilename("/api/attachments.redirect?id=abc", "image/png")
+	if got != "abc.png" {
+		t.Errorf("attachmentFilename() = %q, want %q", got, "abc.png")
+	}
+	got = attachmentFilename("/api/attachments.redirect?id=abc", "application/x-unknown-type")
+	if got != "abc" {
+		t.Errorf("attachmentFilename() = %q, want %q", got, "abc")
+	}
+}
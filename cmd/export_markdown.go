@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/jira"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/spf13/cobra"
+)
+
+// exportPageExpand is the expansion requested for every page walked by
+// export-markdown. It adds the metadata written to the YAML front matter on
+// top of what migrate asks for.
+var exportPageExpand = []string{"version", "body.storage", "children.page", "ancestors", "metadata.labels"}
+
+type MarkdownPage struct {
+	Page *cf.Content
+	// Path is the slash-separated location of the Markdown file, relative to
+	// the output directory.
+	Path     string
+	ParentId string
+	// BlogPost is set when the page is a blog post.
+	BlogPost *confluence.BlogPost
+}
+
+type MarkdownExporter struct {
+	confluenceClient *confluence.ConfluenceExtendedClient
+	jiraClient       *jira.Client
+	spaceKey         string
+	writer           exportWriter
+	rootDir          string
+	frontMatter      bool
+	conversion       conversionOptions
+	selection        selectionOptions
+	blogPosts        bool
+	pages            []*MarkdownPage
+	takenPaths       map[string]bool
+	report           *migrationReport
+	logger           *slog.Logger
+}
+
+// exportWriter receives the files produced by a MarkdownExporter. Names are
+// slash-separated and relative to the root of the export.
+type exportWriter interface {
+	WriteFile(name string, data []byte) error
+	Close() error
+}
+
+// dirWriter writes exported files below a directory on disk.
+type dirWriter struct {
+	dir string
+}
+
+func (w dirWriter) WriteFile(name string, data []byte) error {
+	fullPath := filepath.Join(w.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fullPath, data, 0644)
+}
+
+func (w dirWriter) Close() error {
+	return nil
+}
+
+// zipWriter writes exported files into a zip archive.
+type zipWriter struct {
+	file *os.File
+	zip  *zip.Writer
+}
+
+func newZipWriter(filename string) (*zipWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &zipWriter{file: file, zip: zip.NewWriter(file)}, nil
+}
+
+func (w *zipWriter) WriteFile(name string, data []byte) error {
+	fw, err := w.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	if err := w.zip.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// exportMarkdownCmd represents the export-markdown command
+var exportMarkdownCmd = &cobra.Command{
+	Use:   "export-markdown",
+	Short: "Export confluence pages to a Markdown directory tree",
+	Long: `Exports every page of a confluence space as a Markdown file with YAML front matter.
+Folders mirror the page hierarchy, images are stored next to the pages that use them
+and links between pages are rewritten to relative file links, so the result can be
+committed to Git or opened as an Obsidian vault.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		lvl := new(slog.LevelVar)
+		levelString := cmd.Flag("log").Value.String()
+		lvl.UnmarshalText([]byte(levelString))
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: lvl,
+		}))
+
+		fatal := func(msg string, err error) {
+			if err != nil {
+				logger.Error(msg, "error", err)
+			} else {
+				logger.Error(msg)
+			}
+			os.Exit(1)
+		}
+
+		spaceKey, err := cmd.Flags().GetString("from")
+		if err != nil {
+			fatal("Error getting --from flag", err)
+		}
+
+		outputDir, err := cmd.Flags().GetString("out")
+		if err != nil {
+			fatal("Error getting --out flag", err)
+		}
+
+		conversion, err := conversionOptionsFromFlags(cmd)
+		if err != nil {
+			fatal(err.Error(), nil)
+		}
+
+		selection, err := selectionOptionsFromFlags(cmd)
+		if err != nil {
+			fatal(err.Error(), nil)
+		}
+
+		blogPosts, err := cmd.Flags().GetBool("blog-posts")
+		if err != nil {
+			fatal("Error getting --blog-posts flag", err)
+		}
+
+		confluenceClient, err := confluence.GetClient()
+		if err != nil {
+			fatal("Error creating Confluence client", err)
+		}
+
+		exporter := MarkdownExporter{
+			confluenceClient: confluenceClient,
+			jiraClient:       getJiraClient(logger),
+			spaceKey:         spaceKey,
+			writer:           dirWriter{dir: outputDir},
+			frontMatter:      true,
+			conversion:       conversion,
+			selection:        selection,
+			blogPosts:        blogPosts,
+			takenPaths:       make(map[string]bool),
+			report:           &migrationReport{},
+			logger:           logger,
+		}
+
+		logger.Info("Exporting confluence pages to Markdown", "spaceKey", spaceKey, "outputDir", outputDir)
+
+		if err := exporter.exportSpace(); err != nil {
+			fatal("Export failed", err)
+		}
+		logger.Info("Exported Markdown pages", "pageCount", len(exporter.pages), "outputDir", outputDir)
+	},
+}
+
+// exportSpace walks the selected pages and blog posts of the space, then
+// converts and writes every page.
+func (e *MarkdownExporter) exportSpace() error {
+	selector, err := newPageSelector(e.confluenceClient, e.spaceKey, e.selection, exportPageExpand, e.logger)
+	if err != nil {
+		return err
+	}
+	rootPages, err := selector.rootPages()
+	if err != nil {
+		return fmt.Errorf("failed to get Confluence space content: %w", err)
+	}
+	for _, rootPage := range rootPages {
+		if err := e.collectPagesRecurse(selector, rootPage, e.rootDir, ""); err != nil {
+			return err
+		}
+	}
+	if e.blogPosts {
+		posts, err := selector.blogPosts()
+		if err != nil {
+			return err
+		}
+		e.collectBlogPosts(posts)
+	}
+
+	linkMap := e.buildLinkMap()
+	for _, page := range e.pages {
+		if err := e.exportPage(page, linkMap); err != nil {
+			return err
+		}
+	}
+	e.report.output()
+
+	return e.writer.Close()
+}
+
+// collectPagesRecurse walks the page tree and assigns each page its Markdown
+// path before anything is written, so that links to pages exported later can
+// already be resolved.
+func (e *MarkdownExporter) collectPagesRecurse(selector *pageSelector, page *cf.Content, dir string, parentId string) error {
+	name := e.uniqueName(dir, sanitizeFilename(page.Title), page.ID)
+	markdownPage := &MarkdownPage{
+		Page:     page,
+		Path:     path.Join(dir, name+".md"),
+		ParentId: parentId,
+	}
+	e.pages = append(e.pages, markdownPage)
+
+	childPages, err := selector.children(page)
+	if err != nil {
+		return err
+	}
+	for _, childPage := range childPages {
+		if err := e.collectPagesRecurse(selector, childPage, path.Join(dir, name), page.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectBlogPosts assigns each blog post a path in a Blog folder, below a
+// folder per year and month.
+func (e *MarkdownExporter) collectBlogPosts(posts []*confluence.BlogPost) {
+	for _, year := range blogArchive(posts) {
+		for _, month := range year.months {
+			dir := path.Join(e.rootDir, blogTitle, strconv.Itoa(year.year), month.title())
+			for _, post := range month.posts {
+				e.pages = append(e.pages, &MarkdownPage{
+					Page:     post.Content,
+					Path:     path.Join(dir, e.uniqueName(dir, sanitizeFilename(post.Title), post.ID)+".md"),
+					BlogPost: post,
+				})
+			}
+		}
+	}
+}
+
+// uniqueName returns name, or name suffixed with the page id when a sibling
+// with the same sanitized title was already collected.
+func (e *MarkdownExporter) uniqueName(dir, name, pageId string) string {
+	if e.takenPaths[path.Join(dir, name)] {
+		name = name + " (" + pageId + ")"
+	}
+	e.takenPaths[path.Join(dir, name)] = true
+	return name
+}
+
+// uniqueAttachmentName returns name, or name with a numeric suffix before its
+// extension when a name that differs from it only in case is already taken,
+// so that attachments of a page do not overwrite each other on
+// case-insensitive file systems either.
+func uniqueAttachmentName(name string, taken map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for n := 2; taken[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	taken[strings.ToLower(unique)] = true
+	return unique
+}
+
+// buildLinkMap maps every Confluence URL of a collected page to its Markdown
+// path.
+func (e *MarkdownExporter) buildLinkMap() map[string]string {
+	linkMap := make(map[string]string)
+	for _, page := range e.pages {
+		confluenceURLs := possibleConfluenceURLs(e.spaceKey, page.Page)
+		if page.BlogPost != nil {
+			confluenceURLs = blogPostURLs(e.spaceKey, page.BlogPost)
+		}
+		for _, confluenceURL := range confluenceURLs {
+			linkMap[confluenceURL] = page.Path
+		}
+	}
+	return linkMap
+}
+
+// pageTree lists the collected pages below rootPageId, or the root pages of
+// the space when rootPageId is "", for children and pagetree macros. The
+// Confluence URLs it links to are rewritten like any other page link.
+func (e *MarkdownExporter) pageTree(rootPageId string) ([]transform.PageLink, error) {
+	var links []transform.PageLink
+	for _, page := range e.pages {
+		if page.ParentId != rootPageId || page.BlogPost != nil {
+			continue
+		}
+		children, err := e.pageTree(page.Page.ID)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, transform.PageLink{Title: page.Page.Title, URL: confluencePageURL(page.Page.ID), Children: children})
+	}
+	return links, nil
+}
+
+func (e *MarkdownExporter) exportPage(page *MarkdownPage, linkMap map[string]string) error {
+	pageDir := path.Dir(page.Path)
+	attachmentDir := path.Join(pageDir, "attachments")
+	takenAttachments := make(map[string]bool)
+	storeAttachment := func(imageData []byte, filename string, contentType string) (string, error) {
+		attachmentName := uniqueAttachmentName(page.Page.ID+"-"+sanitizeFilename(filename), takenAttachments)
+		if err := e.writer.WriteFile(path.Join(attachmentDir, attachmentName), imageData); err != nil {
+			return "", err
+		}
+		return escapePath(path.Join("attachments", attachmentName)), nil
+	}
+	confluenceHostname := strings.TrimSuffix(e.confluenceClient.GetBaseURL(), "/")
+	pipeline := pagePipeline(page.Page, pipelineOptions{
+		confluenceClient: e.confluenceClient,
+		jiraClient:       e.jiraClient,
+		spaceKey:         e.spaceKey,
+		conversion:       e.conversion,
+		store:            storeAttachment,
+		pageTree:         e.pageTree,
+		report:           e.report,
+		logger:           e.logger,
+	})
+	pipeline = append(pipeline,
+		transform.Links{
+			ConfluenceHostname: confluenceHostname,
+			Lookup: func(confluencePath string) (string, bool) {
+				targetPath, ok := linkMap[confluencePath]
+				if !ok {
+					return "", false
+				}
+				return escapePath(relativeMarkdownPath(pageDir, targetPath)), true
+			},
+		},
+	)
+
+	if page.BlogPost != nil {
+		pipeline = append(pipeline, publishedHeader(page.BlogPost))
+	}
+
+	doc, err := exportAndTransform(e.confluenceClient, page.Page, e.spaceKey, e.conversion, e.logger, pipeline)
+	if err != nil {
+		return err
+	}
+	markdown, err := htmlToMarkdown(doc)
+	if err != nil {
+		return fmt.Errorf("failed to convert page %s (%s) to Markdown: %w", page.Page.ID, page.Page.Title, err)
+	}
+
+	if e.frontMatter {
+		markdown = markdownFrontMatter(page, e.spaceKey, confluenceHostname) + markdown
+	}
+	if err := e.writer.WriteFile(page.Path, []byte(markdown)); err != nil {
+		return fmt.Errorf("failed to write Markdown for page %s (%s): %w", page.Page.ID, page.Page.Title, err)
+	}
+	e.logger.Info("Exported page", "pageId", page.Page.ID, "path", page.Path)
+	return nil
+}
+
+// markdownFrontMatter renders the YAML front matter holding the Confluence
+// metadata of page. Strings are emitted double-quoted, which YAML parses with
+// the same escapes as Go.
+func markdownFrontMatter(page *MarkdownPage, spaceKey, confluenceHostname string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(page.Page.Title))
+	fmt.Fprintf(&b, "confluence_id: %s\n", strconv.Quote(page.Page.ID))
+	fmt.Fprintf(&b, "confluence_space: %s\n", strconv.Quote(spaceKey))
+	fmt.Fprintf(&b, "confluence_url: %s\n", strconv.Quote(confluenceHostname+confluencePageURL(page.Page.ID)))
+	if page.ParentId != "" {
+		fmt.Fprintf(&b, "confluence_parent_id: %s\n", strconv.Quote(page.ParentId))
+	}
+	if version := page.Page.Version; version != nil {
+		fmt.Fprintf(&b, "version: %d\n", version.Number)
+		if version.When != nil {
+			fmt.Fprintf(&b, "updated: %s\n", version.When.Format(time.RFC3339))
+		}
+		if version.By != nil && version.By.DisplayName != "" {
+			fmt.Fprintf(&b, "updated_by: %s\n", strconv.Quote(version.By.DisplayName))
+		}
+	}
+	if post := page.BlogPost; post != nil {
+		fmt.Fprintf(&b, "published: %s\n", post.Published.Format(time.RFC3339))
+		if post.Author != "" {
+			fmt.Fprintf(&b, "author: %s\n", strconv.Quote(post.Author))
+		}
+	}
+	if labels := pageLabels(page.Page); len(labels) > 0 {
+		b.WriteString("labels:\n")
+		for _, label := range labels {
+			fmt.Fprintf(&b, "  - %s\n", strconv.Quote(label))
+		}
+	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+func pageLabels(page *cf.Content) []string {
+	if page.Metadata == nil || page.Metadata.Labels == nil {
+		return nil
+	}
+	var labels []string
+	for _, label := range page.Metadata.Labels.Result {
+		labels = append(labels, label.Name)
+	}
+	return labels
+}
+
+// sanitizeFilename replaces characters that are not allowed in file names on
+// common file systems, and the dots that would hide or escape a file.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '-'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// relativeMarkdownPath returns the path of target relative to the directory
+// fromDir, both slash-separated and relative to the export root.
+func relativeMarkdownPath(fromDir, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(fromDir), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+// escapePath percent-encodes each segment of a slash-separated path so it can
+// be used as a Markdown link target.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+func init() {
+	rootCmd.AddCommand(exportMarkdownCmd)
+	exportMarkdownCmd.PersistentFlags().String("from", "", "Confluence SpaceKey to export pages from")
+	exportMarkdownCmd.MarkPersistentFlagRequired("from")
+	exportMarkdownCmd.PersistentFlags().String("out", "markdown", "Directory to write the Markdown tree into")
+	addConversionFlags(exportMarkdownCmd)
+	exportMarkdownCmd.PersistentFlags().Bool("blog-posts", false, "Also export the blog posts of the space, into a Blog folder organised by year and month.")
+	addSelectionFlags(exportMarkdownCmd)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{name: "plain title is kept", title: "Release Notes", want: "Release Notes"},
+		{name: "path separators are replaced", title: "CI/CD: How-to", want: "CI-CD- How-to"},
+		{name: "leading dots are trimmed", title: "..hidden", want: "hidden"},
+		{name: "empty title falls back", title: "  ", want: "untitled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.title); got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueAttachmentName(t *testing.T) {
+	taken := make(map[string]bool)
+	var got []string
+	for _, name := range []string{"1-table.svg", "1-Table.svg", "1-table.svg", "1-notes", "1-notes"} {
+		got = append(got, uniqueAttachmentName(name, taken))
+	}
+	want := []string{"1-table.svg", "1-Table-2.svg", "1-table-3.svg", "1-notes", "1-notes-2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueAttachmentName() = %q, want %q", got, want)
+	}
+}
+
+func TestRelativeMarkdownPath(t *testing.T) {
+	tests := []struct {
+		fromDir string
+		target  string
+		want    string
+	}{
+		{fromDir: ".", target: "Home.md", want: "Home.md"},
+		{fromDir: "Home", target: "Home.md", want: "../Home.md"},
+		{fromDir: "Home/Guides", target: "Home/Reference/API.md", want: "../Reference/API.md"},
+	}
+
+	for _, tt := range tests {
+		if got := relativeMarkdownPath(tt.fromDir, tt.target); got != tt.want {
+			t.Errorf("relativeMarkdownPath(%q, %q) = %q, want %q", tt.fromDir, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	got := escapePath("../Release Notes/v1 (draft).md")
+	want := "../Release%20Notes/v1%20%28draft%29.md"
+	if got != want {
+		t.Errorf("escapePath() = %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownFrontMatter(t *testing.T) {
+	page := &MarkdownPage{
+		Page: &cf.Content{
+			ID:      "42",
+			Title:   `Say "hi"`,
+			Version: &cf.Version{Number: 3},
+			Metadata: &cf.Metadata{Labels: &cf.LabelCollection{Result: []*cf.Label{
+				{Name: "runbook"},
+			}}},
+		},
+		ParentId: "7",
+	}
+
+	got := markdownFrontMatter(page, "ENG", "https://confluence.example.com")
+	want := strings.Join([]string{
+		"---",
+		`title: "Say \"hi\""`,
+		`confluence_id: "42"`,
+		`confluence_space: "ENG"`,
+		`confluence_url: "https://confluence.example.com/pages/viewpage.action?pageId=42"`,
+		`confluence_parent_id: "7"`,
+		"version: 3",
+		"labels:",
+		`  - "runbook"`,
+		"---",
+		"",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("markdownFrontMatter() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestZipWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "import.zip")
+	writer, err := newZipWriter(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteFile("Space/Home.md", []byte("# Home\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if len(reader.File) != 1 || reader.File[0].Name != "Space/Home.md" {
+		t.Fatalf("unexpected zip entries: %+v", reader.File)
+	}
+	rc, err := reader.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if string(data) != "# Home\n" {
+		t.Errorf("zip entry = %q, want %q", data, "# Home\n")
+	}
+}
+
+func TestMarkdownExporterPageTree(t *testing.T) {
+	exporter := MarkdownExporter{pages: []*MarkdownPage{
+		{Page: &cf.Content{ID: "1", Title: "Home"}},
+		{Page: &cf.Content{ID: "2", Title: "Setup"}, ParentId: "1"},
+		{Page: &cf.Content{ID: "3", Title: "Linux"}, ParentId: "2"},
+		{Page: &cf.Content{ID: "4", Title: "FAQ"}, ParentId: "1"},
+	}}
+	got, err := exporter.pageTree("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []transform.PageLink{
+		{Title: "Setup", URL: "/pages/viewpage.action?pageId=2", Children: []transform.PageLink{
+			{Title: "Linux", URL: "/pages/viewpage.action?pageId=3"},
+		}},
+		{Title: "FAQ", URL: "/pages/viewpage.action?pageId=4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pageTree() = %+v, want %+v", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/oskarspakers/confluence-to-outline/outline"
+	"github.com/oskarspakers/confluence-to-outline/transform"
 
 	"github.com/spf13/cobra"
 )
@@ -23,3 +24,78 @@ func outlineRateLimitFromFlags(cmd *cobra.Command) (outline.RateLimit, error) {
 		Window:   time.Duration(windowSeconds) * time.Second,
 	}, nil
 }
+
+// Page representations conversion can start from.
+const (
+	sourceFormatExport  = "export"
+	sourceFormatStorage = "storage"
+	sourceFormatADF     = "adf"
+)
+
+// conversionOptions are the flags controlling how page content is converted
+// that migrate and export-markdown share.
+type conversionOptions struct {
+	sourceFormat     string
+	includeMode      string
+	plantUMLServer   string
+	layoutSeparators bool
+	complexTables    string
+	regenerateTOC    bool
+}
+
+func conversionOptionsFromFlags(cmd *cobra.Command) (conversionOptions, error) {
+	sourceFormat, err := cmd.Flags().GetString("source-format")
+	if err != nil {
+		return conversionOptions{}, fmt.Errorf("Error getting --source-format flag: %w", err)
+	}
+	if sourceFormat != sourceFormatExport && sourceFormat != sourceFormatStorage && sourceFormat != sourceFormatADF {
+		return conversionOptions{}, fmt.Errorf("invalid --source-format %q: must be %s, %s or %s", sourceFormat, sourceFormatStorage, sourceFormatExport, sourceFormatADF)
+	}
+	includeMode, err := cmd.Flags().GetString("include-mode")
+	if err != nil {
+		return conversionOptions{}, fmt.Errorf("Error getting --include-mode flag: %w", err)
+	}
+	if includeMode != transform.IncludeInline && includeMode != transform.IncludeLink {
+		return conversionOptions{}, fmt.Errorf("invalid --include-mode %q: must be %s or %s", includeMode, transform.IncludeInline, transform.IncludeLink)
+	}
+	plantUMLServer, err := cmd.Flags().GetString("plantuml-server")
+	if err != nil {
+		return conversionOptions{}, fmt.Errorf("Error getting --plantuml-server flag: %w", err)
+	}
+	layoutSeparators, err := cmd.Flags().GetBool("layout-separators")
+	if err != nil {
+		return conversionOptions{}, fmt.Errorf("Error getting --layout-separators flag: %w", err)
+	}
+	complexTables, err := cmd.Flags().GetString("complex-tables")
+	if err != nil {
+		return conversionOptions{}, fmt.Errorf("Error getting --complex-tables flag: %w", err)
+	}
+	if complexTables != transform.TablesNormalize && complexTables != transform.TablesPreformatted && complexTables != transform.TablesImage {
+		return conversionOptions{}, fmt.Errorf("invalid --complex-tables %q: must be %s, %s or %s", complexTables, transform.TablesNormalize, transform.TablesPreformatted, transform.TablesImage)
+	}
+	toc, err := cmd.Flags().GetString("toc")
+	if err != nil {
+		return conversionOptions{}, fmt.Errorf("Error getting --toc flag: %w", err)
+	}
+	if toc != "drop" && toc != "regenerate" {
+		return conversionOptions{}, fmt.Errorf("invalid --toc %q: must be drop or regenerate", toc)
+	}
+	return conversionOptions{
+		sourceFormat:     sourceFormat,
+		includeMode:      includeMode,
+		plantUMLServer:   plantUMLServer,
+		layoutSeparators: layoutSeparators,
+		complexTables:    complexTables,
+		regenerateTOC:    toc == "regenerate",
+	}, nil
+}
+
+// addConversionFlags registers the flags read by conversionOptionsFromFlags.
+func addConversionFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("source-format", sourceFormatExport, "Page representation to convert: export is Confluence's rendered export view, storage the storage format, which keeps macro parameters and names the pages and attachments that links and images point at, adf the Atlassian Document Format of Confluence Cloud's editor.")
+	cmd.PersistentFlags().String("include-mode", transform.IncludeInline, "How to convert include and excerpt-include macros: inline the current content of the included page followed by a link to it, or link replaces the macro with a link to the included page.")
+	cmd.PersistentFlags().String("plantuml-server", "", "PlantUML server URL (e.g. https://www.plantuml.com/plantuml) to render PlantUML macros with. The diagram source is always kept as a code block.")
+	cmd.PersistentFlags().Bool("layout-separators", false, "Put a horizontal rule between the columns of multi-column page layouts, which are placed one after another.")
+	cmd.PersistentFlags().String("toc", "drop", "What to do with table of contents macros: drop them (Outline shows its own contents sidebar) or regenerate them as a list of links to the headings of the page.")
+	cmd.PersistentFlags().String("complex-tables", transform.TablesNormalize, "How to convert tables with merged cells or nested tables: normalize splits merged cells and flattens nested tables, preformatted replaces the table with a text grid, image with an SVG rendering of it. Affected pages are listed in migrationReport.json.")
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/oskarspakers/confluence-to-outline/outline"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/google/uuid"
+)
+
+// What happens to the home page of the space.
+const (
+	homePageDocument = "document"
+	homePageOverview = "overview"
+)
+
+// collectionURL returns the relative URL of the collection in the body of a
+// collections.info response, or "" when it has none. The generated client
+// leaves the URL out of Collection.
+func collectionURL(body []byte) string {
+	var resp struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ""
+	}
+	return resp.Data.URL
+}
+
+// overviewRootPages makes the home page of the space the overview of the
+// collection: it returns the home page and rootPages with the home page
+// replaced by its selected children, and points links to the home page at
+// the collection. It falls back to importing the home page as a document,
+// returning nil and rootPages as they are, when the space has no home page,
+// the home page is not one of rootPages, or viewing it is restricted and the
+// restricted-pages policy would not publish it.
+func (m *Migrator) overviewRootPages(rootPages []*cf.Content, collectionURL string) (*cf.Content, []*cf.Content, error) {
+	homePageId, err := m.confluenceClient.GetHomePageID(m.spaceKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get home page of space %s: %w", m.spaceKey, err)
+	}
+	i := slices.IndexFunc(rootPages, func(page *cf.Content) bool {
+		return page.ID == homePageId
+	})
+	if homePageId == "" || i < 0 {
+		m.logger.Warn("Home page is not a migrated top-level page, importing it as a document", "pageId", homePageId)
+		return nil, rootPages, nil
+	}
+	homePage := rootPages[i]
+	restricted, err := m.viewRestricted(homePage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if restricted {
+		m.logger.Warn("Home page is view-restricted, importing it as a document", "pageId", homePage.ID, "pageTitle", homePage.Title)
+		return nil, rootPages, nil
+	}
+	children, err := m.selector.children(homePage)
+	if err != nil {
+		return nil, nil, err
+	}
+	// An empty document id marks the collection, which has no document for
+	// fixURLs to rewrite.
+	if collectionURL != "" {
+		for _, confluenceURL := range possibleConfluenceURLs(m.spaceKey, homePage) {
+			m.urlMap = updateUrlMap(m.urlMap, confluenceURL, collectionURL, "")
+		}
+	}
+	m.logger.Info("Using home page as collection overview", "pageId", homePage.ID, "pageTitle", homePage.Title, "childPageCount", len(children))
+	return homePage, slices.Replace(slices.Clone(rootPages), i, i+1, children...), nil
+}
+
+// writeOverview converts the home page and writes it as the description of
+// the collection, once every page has been mapped to its document.
+func (m *Migrator) writeOverview(homePage *cf.Content) error {
+	doc, err := exportAndTransform(m.confluenceClient, homePage, m.spaceKey, m.conversion, m.logger, append(m.pagePipeline(homePage), m.linksTransformer()))
+	if err != nil {
+		return err
+	}
+	markdown, err := htmlToMarkdown(doc)
+	if err != nil {
+		return fmt.Errorf("failed to convert page %s (%s) to Markdown: %w", homePage.ID, homePage.Title, err)
+	}
+	description := stripTitleHeading(markdown)
+	resp, err := m.outlineClient.Client.PostCollectionsUpdateWithResponse(context.Background(), outline.PostCollectionsUpdateJSONRequestBody{
+		Id:          uuid.MustParse(m.collectionId),
+		Description: &description,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update collection %s: %w", m.collectionId, err)
+	}
+	if resp.JSON200 == nil {
+		return fmt.Errorf("updating collection %s failed: status %d body: %s", m.collectionId, resp.StatusCode(), string(resp.Body))
+	}
+	m.logger.Info("Wrote collection overview", "collectionId", m.collectionId, "pageId", homePage.ID, "pageTitle", homePage.Title)
+	return nil
+}
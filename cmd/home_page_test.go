@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"testing"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+func TestCollectionURL(t *testing.T) {
+	body := []byte(`{"data":{"id":"7c3c8d8e-1a2b-4c5d-8e9f-0a1b2c3d4e5f","name":"Engineering","url":"/collection/engineering-AbC123"},"ok":true}`)
+	if got, want := collectionURL(body), "/collection/engineering-AbC123"; got != want {
+		t.Errorf("collectionURL() = %q, want %q", got, want)
+	}
+	if got := collectionURL([]byte(`not json`)); got != "" {
+		t.Errorf("collectionURL() of an invalid body = %q, want none", got)
+	}
+}
+
+// Pages below the home page, which became the collection overview, are
+// placed where its children were moved to.
+func TestMigratedDocumentHomePage(t *testing.T) {
+	m := &Migrator{urlMap: make(map[string]UrlMapEntry), parentDocumentId: "parent"}
+	updateUrlMap(m.urlMap, confluencePageURL("1"), "/collection/engineering-AbC123", "")
+	updateUrlMap(m.urlMap, confluencePageURL("2"), "/doc/team-XyZ789", "doc-2")
+
+	archived := &cf.Content{ID: "3", Ancestors: []*cf.Content{{ID: "1"}}}
+	if docId, ok := ancestorDocument(archived, m.migratedDocument); !ok || docId != "parent" {
+		t.Errorf("ancestorDocument() below the home page = %q, %v, want the parent document", docId, ok)
+	}
+	archived.Ancestors = append(archived.Ancestors, &cf.Content{ID: "2"})
+	if docId, ok := ancestorDocument(archived, m.migratedDocument); !ok || docId != "doc-2" {
+		t.Errorf("ancestorDocument() = %q, %v, want doc-2", docId, ok)
+	}
+}
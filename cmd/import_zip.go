@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+)
+
+// writeOutlineImportZip writes the space into a zip in Outline's Markdown
+// import format: one top-level folder named after the space that becomes the
+// collection, a Markdown file per page with child pages in a folder of the
+// same name, and images stored in the zip and referenced by relative path.
+// Outline resolves relative links between the files on import, so the zip
+// can be uploaded through Settings → Import without any API calls.
+func writeOutlineImportZip(spaceKey string, filename string, conversion conversionOptions, selection selectionOptions, blogPosts bool, logger *slog.Logger) error {
+	confluenceClient, err := confluence.GetClient()
+	if err != nil {
+		return fmt.Errorf("failed to create Confluence client: %w", err)
+	}
+
+	space, err := confluenceClient.GetSpace(spaceKey)
+	if err != nil {
+		return fmt.Errorf("failed to get Confluence space: %w", err)
+	}
+
+	writer, err := newZipWriter(filename)
+	if err != nil {
+		return err
+	}
+
+	exporter := MarkdownExporter{
+		confluenceClient: confluenceClient,
+		jiraClient:       getJiraClient(logger),
+		spaceKey:         spaceKey,
+		writer:           writer,
+		rootDir:          sanitizeFilename(space.Name),
+		conversion:       conversion,
+		selection:        selection,
+		blogPosts:        blogPosts,
+		takenPaths:       make(map[string]bool),
+		report:           &migrationReport{},
+		logger:           logger,
+	}
+
+	logger.Info("Writing Outline import zip", "spaceKey", spaceKey, "spaceName", space.Name, "file", filename)
+	if err := exporter.exportSpace(); err != nil {
+		writer.Close()
+		return err
+	}
+	logger.Info("Wrote Outline import zip", "pageCount", len(exporter.pages), "file", filename)
+	return nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/oskarspakers/confluence-to-outline/jira"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+// getJiraClient returns a Jira client, or nil when Jira is not configured,
+// in which case jira macros are converted from what Confluence rendered.
+func getJiraClient(logger *slog.Logger) *jira.Client {
+	jiraClient, err := jira.GetClient()
+	if err != nil {
+		logger.Info("Jira issue lookups disabled", "reason", err)
+		return nil
+	}
+	return jiraClient
+}
+
+// jiraTransformer returns the Jira step for page.
+func jiraTransformer(page *cf.Content, jiraClient *jira.Client, logger *slog.Logger) transform.Jira {
+	jiraStep := transform.Jira{
+		SnapshotDate: time.Now().Format("2006-01-02"),
+		Logger:       logger,
+	}
+	if jiraClient != nil {
+		jiraStep.BaseURL = jiraClient.GetBaseURL()
+		jiraStep.GetIssue = func(key string) (transform.JiraIssue, error) {
+			issue, err := jiraClient.GetIssue(key)
+			if err != nil {
+				return transform.JiraIssue{}, err
+			}
+			return jiraIssueSnapshot(*issue), nil
+		}
+		jiraStep.Search = func(jql string, maxIssues int) ([]transform.JiraIssue, int, error) {
+			issues, total, err := jiraClient.Search(jql, maxIssues)
+			if err != nil {
+				return nil, 0, err
+			}
+			var snapshots []transform.JiraIssue
+			for _, issue := range issues {
+				snapshots = append(snapshots, jiraIssueSnapshot(issue))
+			}
+			return snapshots, total, nil
+		}
+	}
+	if page.Body == nil || page.Body.StorageView == nil {
+		return jiraStep
+	}
+	refs, err := transform.ParseJiraReferences(page.Body.StorageView.Value)
+	if err != nil {
+		logger.Warn("Failed to parse storage format, jira macros are kept as exported", "pageId", page.ID, "pageTitle", page.Title, "error", err)
+		return jiraStep
+	}
+	jiraStep.References = refs
+	return jiraStep
+}
+
+func jiraIssueSnapshot(issue jira.Issue) transform.JiraIssue {
+	snapshot := transform.JiraIssue{
+		Key:     issue.Key,
+		Summary: issue.Fields.Summary,
+		Created: issue.Fields.Created,
+		Updated: issue.Fields.Updated,
+	}
+	if issue.Fields.Status != nil {
+		snapshot.Status = issue.Fields.Status.Name
+	}
+	if issue.Fields.IssueType != nil {
+		snapshot.Type = issue.Fields.IssueType.Name
+	}
+	if issue.Fields.Priority != nil {
+		snapshot.Priority = issue.Fields.Priority.Name
+	}
+	if issue.Fields.Assignee != nil {
+		snapshot.Assignee = issue.Fields.Assignee.DisplayName
+	}
+	if issue.Fields.Resolution != nil {
+		snapshot.Resolution = issue.Fields.Resolution.Name
+	}
+	return snapshot
+}
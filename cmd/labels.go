@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/gosimple/slug"
+)
+
+// labelsTitle is the title of the document the label index documents are
+// created below.
+const labelsTitle = "Labels"
+
+// labelledDocument is a migrated page listed in the index document of one of
+// its labels.
+type labelledDocument struct {
+	title string
+	url   string
+}
+
+// confluenceLabelURL returns the relative URL of the Confluence page listing
+// the pages of the space with label. Labels headers link to it, and it is
+// mapped to the index document of the label like a page URL.
+func confluenceLabelURL(spaceKey, label string) string {
+	return "/label/" + spaceKey + "/" + url.PathEscape(label)
+}
+
+// labelsHeader returns the step listing the labels of page below its title,
+// each linking to its index document.
+func (m Migrator) labelsHeader(page *cf.Content) transform.LabelsHeader {
+	return transform.LabelsHeader{
+		Labels: pageLabels(page),
+		URL: func(label string) string {
+			return confluenceLabelURL(m.spaceKey, label)
+		},
+	}
+}
+
+// indexLabels adds the document page was migrated to to the index of each of
+// its labels. Unpublished documents and documents in the restricted
+// collection are left out of the lists, so that the index does not reveal
+// them, but their labels still get an index document for their headers to
+// link to.
+func (m *Migrator) indexLabels(page *cf.Content, document labelledDocument) {
+	if m.labelIndex == nil {
+		return
+	}
+	listed := !m.unpublished && !(m.restricted && m.policies.restricted == restrictedCollection)
+	for _, label := range pageLabels(page) {
+		if listed {
+			m.labelIndex[label] = append(m.labelIndex[label], document)
+		} else if _, ok := m.labelIndex[label]; !ok {
+			m.labelIndex[label] = nil
+		}
+	}
+}
+
+// createLabelIndex creates a document per label below a Labels document,
+// listing the migrated pages with the label, and maps the Confluence label
+// URLs to them. It runs once every page has been mapped to its document.
+func (m *Migrator) createLabelIndex() error {
+	if len(m.labelIndex) == 0 {
+		return nil
+	}
+	labelsId, err := m.createContainerDocument(m.collectionId, labelsTitle, m.parentDocumentId)
+	if err != nil {
+		return err
+	}
+	labels := make([]string, 0, len(m.labelIndex))
+	for label := range m.labelIndex {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing keeps the labels sorted.
+	for i := len(labels) - 1; i >= 0; i-- {
+		text, err := labelIndexText(m.labelIndex[labels[i]])
+		if err != nil {
+			return fmt.Errorf("failed to write index of label %s: %w", labels[i], err)
+		}
+		document, err := m.createDocument(m.collectionId, labels[i], text, labelsId)
+		if err != nil {
+			return err
+		}
+		destOutlineUrl := fmt.Sprintf(`/doc/%s-%s`, slug.Make(labels[i]), *document.UrlId)
+		m.urlMap = updateUrlMap(m.urlMap, confluenceLabelURL(m.spaceKey, labels[i]), destOutlineUrl, document.Id.String())
+	}
+	return nil
+}
+
+// labelIndexText returns the Markdown list of links to documents, sorted by
+// title.
+func labelIndexText(documents []labelledDocument) (string, error) {
+	documents = slices.Clone(documents)
+	slices.SortStableFunc(documents, func(a, b labelledDocument) int {
+		return cmp.Compare(strings.ToLower(a.title), strings.ToLower(b.title))
+	})
+	doc, err := transform.Parse("")
+	if err != nil {
+		return "", err
+	}
+	list := transform.Element("ul")
+	for _, document := range documents {
+		list.AppendChild(transform.AppendChildren(transform.Element("li"),
+			transform.AppendChildren(transform.Element("a", "href", document.url), transform.Text(document.title))))
+	}
+	transform.Body(doc).AppendChild(list)
+	return htmlToMarkdown(doc)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+func testLabelledPage(id string, labels ...string) *cf.Content {
+	page := &cf.Content{ID: id, Metadata: &cf.Metadata{Labels: &cf.LabelCollection{}}}
+	for _, label := range labels {
+		page.Metadata.Labels.Result = append(page.Metadata.Labels.Result, &cf.Label{Name: label})
+	}
+	return page
+}
+
+func TestIndexLabels(t *testing.T) {
+	m := &Migrator{
+		labelIndex: make(map[string][]labelledDocument),
+		policies:   pagePolicies{restricted: restrictedCollection},
+	}
+	setup := labelledDocument{title: "Setup", url: "/doc/setup-1"}
+	m.indexLabels(testLabelledPage("1", "howto", "team"), setup)
+
+	draftMigrator := *m
+	draftMigrator.unpublished = true
+	draftMigrator.indexLabels(testLabelledPage("2", "howto", "draft"), labelledDocument{title: "Draft", url: "/doc/draft-2"})
+
+	restrictedMigrator := *m
+	restrictedMigrator.restricted = true
+	restrictedMigrator.indexLabels(testLabelledPage("3", "secret"), labelledDocument{title: "Secret", url: "/doc/secret-3"})
+
+	want := map[string][]labelledDocument{
+		"howto":  {setup},
+		"team":   {setup},
+		"draft":  nil,
+		"secret": nil,
+	}
+	if !reflect.DeepEqual(m.labelIndex, want) {
+		t.Errorf("label index = %v, want %v", m.labelIndex, want)
+	}
+}
+
+func TestLabelIndexText(t *testing.T) {
+	text, err := labelIndexText([]labelledDocument{
+		{title: "setup [old]", url: "/doc/setup-old-2"},
+		{title: "Onboarding", url: "/doc/onboarding-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- [Onboarding](/doc/onboarding-1)\n- [setup \\[old\\]](/doc/setup-old-2)\n"
+	if text != want {
+		t.Errorf("got  %q\nwant %q", text, want)
+	}
+}
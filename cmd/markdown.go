@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/base"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/strikethrough"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/table"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+	"golang.org/x/net/html"
+)
+
+// newMarkdownConverter returns the HTML to Markdown converter used for every
+// Markdown output of the tool. Tables and strikethrough are enabled because
+// Outline and most Markdown viewers render the GFM flavour of both; notice
+// blocks are written in Outline's own ":::" syntax, math in its "$$" and
+// "$$$" delimiters and the summary of collapsible blocks as a bold paragraph.
+func newMarkdownConverter() *converter.Converter {
+	conv := converter.NewConverter(
+		converter.WithPlugins(
+			base.NewBasePlugin(),
+			commonmark.NewCommonmarkPlugin(
+				commonmark.WithBulletListMarker("-"),
+			),
+			table.NewTablePlugin(
+				table.WithHeaderPromotion(true),
+			),
+			strikethrough.NewStrikethroughPlugin(),
+		),
+	)
+	conv.Register.RendererFor("div", converter.TagTypeBlock, renderNotice, converter.PriorityEarly)
+	conv.Register.RendererFor("span", converter.TagTypeInline, renderCheckbox, converter.PriorityEarly)
+	conv.Register.RendererFor("math-inline", converter.TagTypeInline, renderMath, converter.PriorityEarly)
+	conv.Register.RendererFor("math-display", converter.TagTypeBlock, renderMath, converter.PriorityEarly)
+	conv.Register.RendererFor("summary", converter.TagTypeBlock, renderSummary, converter.PriorityEarly)
+	return conv
+}
+
+// renderNotice writes notice blocks produced by transform.Notices in
+// Outline's ":::style" container syntax.
+func renderNotice(ctx converter.Context, w converter.Writer, n *html.Node) converter.RenderStatus {
+	style := transform.NoticeStyle(n)
+	if style == "" {
+		return converter.RenderTryNext
+	}
+	var buf bytes.Buffer
+	ctx.RenderChildNodes(ctx, &buf, n)
+
+	w.WriteString("\n\n:::" + style + "\n")
+	w.Write(bytes.TrimSpace(buf.Bytes()))
+	w.WriteString("\n:::\n\n")
+	return converter.RenderSuccess
+}
+
+// renderMath writes math nodes built by transform.Math the way Outline
+// writes math to Markdown, inline formulas as $$tex$$ and display formulas
+// fenced by $$$ lines, so that Outline reads them back as math.
+func renderMath(ctx converter.Context, w converter.Writer, n *html.Node) converter.RenderStatus {
+	tex := strings.TrimSpace(transform.TextContent(n))
+	if n.Data == "math-display" {
+		w.WriteString("\n\n$$$\n" + tex + "\n$$$\n\n")
+	} else {
+		w.WriteString("$$" + tex + "$$")
+	}
+	return converter.RenderSuccess
+}
+
+// renderSummary writes the summary of a <details> block as a bold paragraph.
+// Neither Markdown nor Outline has collapsible blocks, so the content that
+// follows is always shown; transform.Macros reports every such block.
+func renderSummary(ctx converter.Context, w converter.Writer, n *html.Node) converter.RenderStatus {
+	var buf bytes.Buffer
+	ctx.RenderChildNodes(ctx, &buf, n)
+	summary := bytes.TrimSpace(buf.Bytes())
+	if len(summary) == 0 {
+		return converter.RenderSuccess
+	}
+
+	w.WriteString("\n\n**")
+	w.Write(summary)
+	w.WriteString("**\n\n")
+	return converter.RenderSuccess
+}
+
+// renderCheckbox writes the checkbox of a checklist item built by
+// transform.Tasks as a GFM task list marker.
+func renderCheckbox(ctx converter.Context, w converter.Writer, n *html.Node) converter.RenderStatus {
+	if !transform.HasClass(n, "checkbox") || !transform.IsElement(n.Parent, "li") || transform.Attr(n.Parent, "data-type") != "checkbox_item" {
+		return converter.RenderTryNext
+	}
+	if transform.HasClass(n.Parent, "checked") {
+		w.WriteString("[x] ")
+	} else {
+		w.WriteString("[ ] ")
+	}
+	return converter.RenderSuccess
+}
+
+// htmlToMarkdown converts a transformed Confluence page to Markdown.
+func htmlToMarkdown(doc *html.Node) (string, error) {
+	markdown, err := newMarkdownConverter().ConvertNode(doc)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(markdown)) + "\n", nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/oskarspakers/confluence-to-outline/transform"
+)
+
+func TestHtmlToMarkdownNotice(t *testing.T) {
+	doc, err := transform.Parse(`<p>Before</p><div class="notice-block warning"><div class="content"><p><strong>Careful</strong></p><p>Two <em>lines</em></p></div></div><p>After</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := htmlToMarkdown(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Before\n\n:::warning\n**Careful**\n\nTwo *lines*\n:::\n\nAfter\n"
+	if got != want {
+		t.Errorf("htmlToMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestHtmlToMarkdownDetails(t *testing.T) {
+	doc, err := transform.Parse(`<details><summary>More</summary><p>Hidden <b>text</b></p></details>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := htmlToMarkdown(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "**More**\n\nHidden **text**\n"
+	if got != want {
+		t.Errorf("htmlToMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestHtmlToMarkdownChecklist(t *testing.T) {
+	doc, err := transform.Parse(`<ul class="checkbox_list"><li data-type="checkbox_item" class="checked"><span class="checkbox"></span>Done</li>` +
+		`<li data-type="checkbox_item"><span class="checkbox"></span>Todo <em>soon</em></li></ul>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := htmlToMarkdown(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- [x] Done\n- [ ] Todo *soon*\n"
+	if got != want {
+		t.Errorf("htmlToMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestHtmlToMarkdownMath(t *testing.T) {
+	doc, err := transform.Parse(`<p>Energy <math-inline class="math-node">E = mc^2</math-inline> holds.</p>` +
+		`<math-display class="math-node">\sum_{i=1}^{n} x_i &lt; 1</math-display>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := htmlToMarkdown(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// outlineMarkdown is what Outline's Markdown download of a document with
+	// the same formulas holds, so the output imports back as math.
+	outlineMarkdown := "Energy $$E = mc^2$$ holds.\n\n$$$\n\\sum_{i=1}^{n} x_i < 1\n$$$\n"
+	if got != outlineMarkdown {
+		t.Errorf("htmlToMarkdown() = %q, want %q", got, outlineMarkdown)
+	}
+}
@@ -5,19 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/url"
 	"os"
-	"path"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/jira"
 	"github.com/oskarspakers/confluence-to-outline/outline"
+	"github.com/oskarspakers/confluence-to-outline/transform"
 
 	cf "github.com/essentialkaos/go-confluence/v6"
 	"github.com/google/uuid"
 	"github.com/gosimple/slug"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/html"
 )
 
 type UrlMapEntry struct {
@@ -38,13 +40,39 @@ type DocumentData struct {
 	Title   string
 }
 
+// migratePageExpand is the expansion requested for every page migrated.
+var migratePageExpand = []string{"version", "body.storage", "children.page"}
+
 type Migrator struct {
 	confluenceClient *confluence.ConfluenceExtendedClient
 	outlineClient    *outline.OutlineExtendedClient
+	jiraClient       *jira.Client
 	urlMap           map[string]UrlMapEntry
 	spaceKey         string
 	collectionId     string
+	policies         pagePolicies
+	restricted       bool            // migrating the pages below a view-restricted page
+	unpublished      bool            // importing documents unpublished
+	unpublishedDocs  map[string]bool // documents imported unpublished
+	archiveDocIds    *[]string       // documents to archive once written
+	// parentDocumentId is the Outline document the top-level pages are
+	// imported below, or "" to import them at the top of the collection.
+	parentDocumentId string
+	// labelIndex holds the documents listed per label, or is nil without
+	// --label-index.
+	labelIndex map[string][]labelledDocument
+	// blogCollectionId is the Outline collection blog posts are migrated
+	// into, or "" to migrate them below a Blog document of collectionId.
+	// blogPosts holds the blog posts to migrate by id.
+	blogCollectionId string
+	blogPosts        map[string]*confluence.BlogPost
 	markRegex        string
+	repairLinks      bool
+	conversion       conversionOptions
+	selector         *pageSelector
+	rootPages        []*cf.Content
+	pageTrees        map[string][]transform.PageLink
+	report           *migrationReport
 	logger           *slog.Logger
 }
 
@@ -80,6 +108,126 @@ var migrateCmd = &cobra.Command{
 			fatal("Error getting --to flag", err)
 		}
 
+		conversion, err := conversionOptionsFromFlags(cmd)
+		if err != nil {
+			fatal(err.Error(), nil)
+		}
+
+		selection, err := selectionOptionsFromFlags(cmd)
+		if err != nil {
+			fatal(err.Error(), nil)
+		}
+
+		parentDocumentId, err := cmd.Flags().GetString("parent-document")
+		if err != nil {
+			fatal("Error getting --parent-document flag", err)
+		}
+		if _, err := uuid.Parse(parentDocumentId); parentDocumentId != "" && err != nil {
+			fatal(fmt.Sprintf("invalid --parent-document %q: must be a document id", parentDocumentId), nil)
+		}
+
+		blogPosts, err := cmd.Flags().GetBool("blog-posts")
+		if err != nil {
+			fatal("Error getting --blog-posts flag", err)
+		}
+
+		blogCollectionId, err := cmd.Flags().GetString("blog-collection")
+		if err != nil {
+			fatal("Error getting --blog-collection flag", err)
+		}
+		if _, err := uuid.Parse(blogCollectionId); blogCollectionId != "" && err != nil {
+			fatal(fmt.Sprintf("invalid --blog-collection %q: must be a collection id", blogCollectionId), nil)
+		}
+
+		labelIndex, err := cmd.Flags().GetBool("label-index")
+		if err != nil {
+			fatal("Error getting --label-index flag", err)
+		}
+
+		templates, err := cmd.Flags().GetBool("templates")
+		if err != nil {
+			fatal("Error getting --templates flag", err)
+		}
+
+		homePageMode, err := cmd.Flags().GetString("home-page")
+		if err != nil {
+			fatal("Error getting --home-page flag", err)
+		}
+		if homePageMode != homePageDocument && homePageMode != homePageOverview {
+			fatal(fmt.Sprintf("invalid --home-page %q: must be %s or %s", homePageMode, homePageDocument, homePageOverview), nil)
+		}
+		// The overview is the collection description, which a migration into
+		// a parent document must not overwrite.
+		if homePageMode == homePageOverview && parentDocumentId != "" {
+			fatal("--home-page overview cannot be used with --parent-document", nil)
+		}
+
+		policies, err := pagePoliciesFromFlags(cmd)
+		if err != nil {
+			fatal(err.Error(), nil)
+		}
+
+		markRegex, err := cmd.Flags().GetString("mark")
+		if err != nil {
+			fatal("Error getting --mark flag", err)
+		}
+
+		repairLinks, err := cmd.Flags().GetBool("repair-links")
+		if err != nil {
+			fatal("Error getting --repair-links flag", err)
+		}
+
+		twoPhase, err := cmd.Flags().GetBool("two-phase")
+		if err != nil {
+			fatal("Error getting --two-phase flag", err)
+		}
+
+		outputZip, err := cmd.Flags().GetString("output-zip")
+		if err != nil {
+			fatal("Error getting --output-zip flag", err)
+		}
+		if outputZip != "" {
+			if !policies.isDefault() {
+				fatal("--archived-pages, --draft-pages and --restricted-pages cannot be used with --output-zip", nil)
+			}
+			if parentDocumentId != "" {
+				fatal("--parent-document cannot be used with --output-zip", nil)
+			}
+			if blogCollectionId != "" {
+				fatal("--blog-collection cannot be used with --output-zip", nil)
+			}
+			if labelIndex {
+				fatal("--label-index cannot be used with --output-zip", nil)
+			}
+			if templates {
+				fatal("--templates cannot be used with --output-zip", nil)
+			}
+			if homePageMode == homePageOverview {
+				fatal("--home-page overview cannot be used with --output-zip", nil)
+			}
+			if collectionId != "" {
+				fatal("--to cannot be used with --output-zip", nil)
+			}
+			if twoPhase {
+				fatal("--two-phase cannot be used with --output-zip", nil)
+			}
+			if markRegex != "" {
+				fatal("--mark cannot be used with --output-zip", nil)
+			}
+			// Links are repaired by default, so only an explicit
+			// --repair-links is rejected.
+			if repairLinks && cmd.Flags().Changed("repair-links") {
+				fatal("--repair-links cannot be used with --output-zip", nil)
+			}
+			if err := writeOutlineImportZip(spaceKey, outputZip, conversion, selection, blogPosts, logger); err != nil {
+				fatal("Writing Outline import zip failed", err)
+			}
+			return
+		}
+		if collectionId == "" {
+			fatal("--to is required unless --output-zip is set", nil)
+		}
+
 		rateLimit, err := outlineRateLimitFromFlags(cmd)
 		if err != nil {
 			fatal(err.Error(), nil)
@@ -106,47 +254,107 @@ var migrateCmd = &cobra.Command{
 			fatal("Error creating Confluence client", err)
 		}
 
-		space, err := confluenceClient.Client.GetSpace(spaceKey, cf.SpaceParameters{
-			SpaceKey: []string{spaceKey},
-		})
+		space, err := confluenceClient.GetSpace(spaceKey)
 		if err != nil {
 			fatal("Error getting Confluence space", err)
 		}
 
-		markRegex, err := cmd.Flags().GetString("mark")
+		expand := migratePageExpand
+		if labelIndex {
+			expand = append(slices.Clone(expand), "metadata.labels")
+		}
+		selector, err := newPageSelector(confluenceClient, spaceKey, selection, expand, logger)
 		if err != nil {
-			fatal("Error getting --mark flag", err)
+			fatal("Error selecting Confluence pages", err)
 		}
 
 		migrator := Migrator{
 			confluenceClient: confluenceClient,
 			outlineClient:    outlineClient,
+			jiraClient:       getJiraClient(logger),
 			urlMap:           make(map[string]UrlMapEntry),
 			spaceKey:         spaceKey,
 			collectionId:     collectionId,
+			policies:         policies,
+			unpublishedDocs:  make(map[string]bool),
+			archiveDocIds:    new([]string),
+			parentDocumentId: parentDocumentId,
+			blogCollectionId: blogCollectionId,
+			blogPosts:        make(map[string]*confluence.BlogPost),
 			markRegex:        markRegex,
+			repairLinks:      repairLinks,
+			conversion:       conversion,
+			selector:         selector,
+			pageTrees:        make(map[string][]transform.PageLink),
+			report:           &migrationReport{},
 			logger:           logger,
 		}
+		if labelIndex {
+			migrator.labelIndex = make(map[string][]labelledDocument)
+		}
 
 		logger.Info("Migrating confluence pages to Outline collection", "spaceKey", spaceKey, "spaceName", space.Name, "collectionId", collectionId, "collectionTitle", collectionTitle)
 
-		rootPages, err := confluenceClient.Client.GetSpaceContent(spaceKey, cf.SpaceParameters{
-			SpaceKey: []string{spaceKey},
-			Expand:   []string{"version", "body.storage", "children.page"},
-			Depth:    "1",
-			Limit:    10,
-		})
+		rootPages, err := selector.rootPages()
 		if err != nil {
 			fatal("Error getting Confluence space content", err)
 		}
-		// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves Confluence order.
-		for i := len(rootPages.Pages.Results) - 1; i >= 0; i-- {
-			if err := migrator.migratePageRecurse(rootPages.Pages.Results[i], ""); err != nil {
+		var homePage *cf.Content
+		if homePageMode == homePageOverview {
+			homePage, rootPages, err = migrator.overviewRootPages(rootPages, collectionURL(collectionInfo.Body))
+			if err != nil {
+				fatal("Error using the home page as collection overview", err)
+			}
+		}
+		migrator.rootPages = rootPages
+		var posts []*confluence.BlogPost
+		if blogPosts {
+			posts, err = selector.blogPosts()
+			if err != nil {
+				fatal("Error getting Confluence blog posts", err)
+			}
+			for _, post := range posts {
+				migrator.blogPosts[post.ID] = post
+			}
+		}
+		if twoPhase {
+			if err := migrator.migrateTwoPhase(rootPages, posts); err != nil {
+				fatal("Migration failed", err)
+			}
+		} else {
+			// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves Confluence order.
+			for i := len(rootPages) - 1; i >= 0; i-- {
+				if err := migrator.migratePageRecurse(rootPages[i], parentDocumentId); err != nil {
+					fatal("Migration failed", err)
+				}
+			}
+			migrate := func(m *Migrator, page *cf.Content, parentDocumentId string) error {
+				return m.migratePageRecurse(page, parentDocumentId)
+			}
+			if err := migrator.migrateBlogPosts(posts, migrate); err != nil {
+				fatal("Migration failed", err)
+			}
+			if err := migrator.migrateStatusPages(migrate); err != nil {
+				fatal("Migration failed", err)
+			}
+			if err := migrator.createLabelIndex(); err != nil {
 				fatal("Migration failed", err)
 			}
+			outputDataToJSON(migrator.urlMap, "urlMap")
+			migrator.fixURLs()
 		}
-		outputDataToJSON(migrator.urlMap, "urlMap")
-		migrator.fixURLs()
+		if homePage != nil {
+			if err := migrator.writeOverview(homePage); err != nil {
+				fatal("Writing collection overview failed", err)
+			}
+		}
+		if templates {
+			if err := migrator.migrateTemplates(); err != nil {
+				fatal("Migrating templates failed", err)
+			}
+		}
+		migrator.archiveDocuments()
+		migrator.report.output()
 
 		if err := os.RemoveAll("export"); err != nil {
 			logger.Warn("Failed to remove export folder", "error", err)
@@ -160,7 +368,12 @@ var migrateCmd = &cobra.Command{
 func (m Migrator) fixURLs() {
 
 	var checkURLs, checkStringJSON []JsonOutputVars
+	var repairedLinks []LinkRepair
 	for _, urlInfo := range m.urlMap {
+		if urlInfo.DocId == "" {
+			// The home page became the collection overview.
+			continue
+		}
 		resp, err := m.outlineClient.Client.PostDocumentsInfoWithResponse(context.Background(), outline.PostDocumentsInfoJSONRequestBody{
 			Id: &urlInfo.DocId,
 		})
@@ -172,6 +385,18 @@ func (m Migrator) fixURLs() {
 		documentData := DocumentData{DocId: (*document.Data.Id).String(), DocBody: *document.Data.Text, Title: *document.Data.Title}
 		for oldUrl, urlInfo := range m.urlMap {
 			documentData.DocBody = m.replaceUrlInDocument(oldUrl, urlInfo, documentData.DocBody)
+		}
+		if m.repairLinks {
+			var repairs []LinkRepair
+			documentData.DocBody, repairs = repairBrokenLinks(documentData.DocBody)
+			for _, repair := range repairs {
+				repair.Id = documentData.DocId
+				repair.Title = documentData.Title
+				repairedLinks = append(repairedLinks, repair)
+				m.logger.Info("Repaired broken link", "documentId", documentData.DocId, "documentTitle", documentData.Title, "target", repair.Target)
+			}
+		}
+		for _, urlInfo := range m.urlMap {
 			checkURLs = m.markBrokenLinks(urlInfo, documentData, checkURLs)
 		}
 		if m.markRegex != "" {
@@ -183,6 +408,9 @@ func (m Migrator) fixURLs() {
 	}
 	outputMarkedPages(checkURLs, "checkURLs")
 	outputMarkedPages(checkStringJSON, "Marked")
+	if m.repairLinks {
+		outputLinkRepairs(repairedLinks, "repairedLinks")
+	}
 }
 
 func outputMarkedPages(data []JsonOutputVars, filename string) {
@@ -251,10 +479,10 @@ func (m Migrator) markRegexFunc(documentData DocumentData, checkStringJSON []Jso
 }
 
 func (m Migrator) updateOutlineDocument(documentData DocumentData) error {
-	publish := true // Document update vars https://www.getoutline.com/developers#tag/Documents/paths/~1documents.update/post
+	publish := !m.unpublishedDocs[documentData.DocId] // Document update vars https://www.getoutline.com/developers#tag/Documents/paths/~1documents.update/post
 	appendDoc := false
 	done := true
-	_, err := m.outlineClient.Client.PostDocumentsUpdateWithResponse(context.Background(), outline.PostDocumentsUpdateJSONRequestBody{
+	resp, err := m.outlineClient.Client.PostDocumentsUpdateWithResponse(context.Background(), outline.PostDocumentsUpdateJSONRequestBody{
 		Id:      documentData.DocId,
 		Title:   &documentData.Title,
 		Text:    &documentData.DocBody,
@@ -262,11 +490,17 @@ func (m Migrator) updateOutlineDocument(documentData DocumentData) error {
 		Publish: &publish,
 		Done:    &done,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	if resp.JSON200 == nil {
+		return fmt.Errorf("status %d body: %s", resp.StatusCode(), string(resp.Body))
+	}
+	return nil
 }
 
 func (m Migrator) importDocumentExportedFromOutline(page *cf.Content, parentDocumentId string, exportedDoc *string) (*outline.PostDocumentsImportResponse, error) {
-	var publish = true
+	var publish = !m.unpublished
 
 	exportedDocBytes, err := os.ReadFile("export/" + *exportedDoc)
 	if err != nil {
@@ -295,123 +529,220 @@ func (m Migrator) importDocumentExportedFromOutline(page *cf.Content, parentDocu
 	return importDocumentRes, nil
 }
 
-func (m Migrator) processImagesInHTMLFile(filename string) error {
-	content, err := os.ReadFile("export/" + filename)
-	if err != nil {
-		return err
+// pagePipeline returns the transformations applied to page after export
+// and before it is written to Outline.
+func (m Migrator) pagePipeline(page *cf.Content) transform.Pipeline {
+	pipeline := pagePipeline(page, pipelineOptions{
+		confluenceClient: m.confluenceClient,
+		jiraClient:       m.jiraClient,
+		spaceKey:         m.spaceKey,
+		conversion:       m.conversion,
+		store:            m.outlineClient.UploadAttachment,
+		pageTree:         m.pageTree,
+		report:           m.report,
+		logger:           m.logger,
+	})
+	// Both headers go right below the title, so the labels come after the
+	// publish date.
+	if m.labelIndex != nil {
+		pipeline = append(pipeline, m.labelsHeader(page))
 	}
-
-	htmlContent := string(content)
-	confluenceBase := strings.TrimSuffix(m.confluenceClient.GetBaseURL(), "/")
-
-	// For resolving absolute paths (starting with "/"), use only the scheme+host
-	// to avoid double-path like /wiki/wiki/... when confluenceBase already contains a path.
-	confluenceOrigin := confluenceBase
-	if u, err := url.Parse(confluenceBase); err == nil {
-		confluenceOrigin = u.Scheme + "://" + u.Host
+	if post, ok := m.blogPosts[page.ID]; ok {
+		pipeline = append(pipeline, publishedHeader(post))
 	}
+	return pipeline
+}
 
-	// Match all <img src="..."> occurrences
-	imgSrcRegex := regexp.MustCompile(`(<img[^>]+src=")([^"]+)(")`)
-
-	var processErr error
-	newContent := imgSrcRegex.ReplaceAllStringFunc(htmlContent, func(match string) string {
-		parts := imgSrcRegex.FindStringSubmatch(match)
-		if len(parts) < 4 {
-			return match
-		}
-		imgSrc := parts[2]
-
-		// Resolve to absolute URL; skip external images that aren't from Confluence or Atlassian media
-		var imgURL string
-		if strings.HasPrefix(imgSrc, "http://") || strings.HasPrefix(imgSrc, "https://") {
-			isConfluence := strings.HasPrefix(imgSrc, confluenceBase)
-			isAtlassianMedia := strings.HasPrefix(imgSrc, "https://api.media.atlassian.com/")
-			if !isConfluence && !isAtlassianMedia {
-				return match
+// includesTransformer returns the Includes step for page. Pages of the space
+// are linked by their Confluence URL, which link rewriting points at the
+// migrated document like any other link to the space. Pages of other spaces
+// are not migrated, so they are linked absolutely to Confluence and reported.
+// Only the export view renders the included content, so with another source
+// format the macros become links, which are reported in inline mode.
+func includesTransformer(page *cf.Content, spaceKey, confluenceBaseURL string, conversion conversionOptions, report *migrationReport, logger *slog.Logger) transform.Includes {
+	mode := conversion.includeMode
+	withoutContent := conversion.sourceFormat != sourceFormatExport && mode == transform.IncludeInline
+	if withoutContent {
+		mode = transform.IncludeLink
+	}
+	includes := transform.Includes{
+		Mode: mode,
+		PageURL: func(ref transform.IncludeReference) string {
+			if withoutContent {
+				report.add(page, fmt.Sprintf("Page %q included as a link, as the %s format holds no included content", ref.Title, conversion.sourceFormat))
 			}
-			imgURL = imgSrc
-		} else if strings.HasPrefix(imgSrc, "/") {
-			imgURL = confluenceOrigin + imgSrc
-		} else {
-			return match
-		}
+			if ref.SpaceKey == "" || ref.SpaceKey == spaceKey {
+				return confluenceDisplayURL(spaceKey, ref.Title)
+			}
+			report.add(page, fmt.Sprintf("Page %q included from space %s, linked to Confluence", ref.Title, ref.SpaceKey))
+			return strings.TrimSuffix(confluenceBaseURL, "/") + confluenceDisplayURL(ref.SpaceKey, ref.Title)
+		},
+		Logger: logger,
+	}
+	if page.Body == nil || page.Body.StorageView == nil {
+		return includes
+	}
+	refs, err := transform.ParseIncludeReferences(page.Body.StorageView.Value)
+	if err != nil {
+		logger.Warn("Failed to parse storage format, include macros are kept as exported", "pageId", page.ID, "pageTitle", page.Title, "error", err)
+		return includes
+	}
+	includes.References = refs
+	return includes
+}
 
-		// Strip query params for the filename
-		imgFilename := path.Base(strings.SplitN(imgSrc, "?", 2)[0])
-		if imgFilename == "" || imgFilename == "." {
-			imgFilename = "image.png"
-		}
+// diagramsTransformer returns the Diagrams step for page, storing previews
+// and sources with store.
+func diagramsTransformer(page *cf.Content, confluenceClient *confluence.ConfluenceExtendedClient, store func([]byte, string, string) (string, error), conversion conversionOptions, logger *slog.Logger) transform.Diagrams {
+	diagrams := transform.Diagrams{
+		Attachment: func(filename string) ([]byte, string, error) {
+			return confluenceClient.DownloadAttachment(page.ID, filename)
+		},
+		Download:       confluenceClient.DownloadImage,
+		Store:          store,
+		PlantUMLServer: conversion.plantUMLServer,
+		Logger:         logger,
+	}
+	if page.Body == nil || page.Body.StorageView == nil {
+		return diagrams
+	}
+	refs, err := transform.ParseDiagramReferences(page.Body.StorageView.Value)
+	if err != nil {
+		logger.Warn("Failed to parse storage format, diagram macros are kept as exported", "pageId", page.ID, "pageTitle", page.Title, "error", err)
+		return diagrams
+	}
+	diagrams.References = refs
+	return diagrams
+}
 
-		imageData, contentType, err := m.confluenceClient.DownloadImage(imgURL)
+// pageTree lists the pages below rootPageId, or the root pages of the space
+// when rootPageId is "", for children and pagetree macros. Links use the
+// pageId form of the Confluence URL, which the link-fixing pass points at the
+// migrated documents. Every subtree is fetched from Confluence only once.
+func (m Migrator) pageTree(rootPageId string) ([]transform.PageLink, error) {
+	if links, ok := m.pageTrees[rootPageId]; ok {
+		return links, nil
+	}
+	pages := m.rootPages
+	if rootPageId != "" {
+		rootPage, err := m.confluenceClient.GetPage(rootPageId, []string{"children.page"})
 		if err != nil {
-			m.logger.Warn("Failed to download image", "url", imgURL, "error", err)
-			return match
+			return nil, fmt.Errorf("failed to get page %s: %w", rootPageId, err)
 		}
-
-		// Save image to export/images for inspection
-		_ = os.MkdirAll("export/images", 0755)
-		_ = os.WriteFile("export/images/"+imgFilename, imageData, 0644)
-
-		outlineURL, err := m.outlineClient.UploadAttachment(imageData, imgFilename, contentType)
+		pages = nil
+		if rootPage.Children != nil && rootPage.Children.Pages != nil {
+			pages = rootPage.Children.Pages.Results
+		}
+	}
+	var links []transform.PageLink
+	for _, page := range pages {
+		children, err := m.pageTree(page.ID)
 		if err != nil {
-			m.logger.Warn("Failed to upload image to Outline", "url", imgURL, "error", err)
-			return match
+			return nil, err
 		}
-
-		return parts[1] + outlineURL + parts[3]
-	})
-
-	if processErr != nil {
-		return processErr
+		links = append(links, transform.PageLink{Title: page.Title, URL: confluencePageURL(page.ID), Children: children})
 	}
-
-	return os.WriteFile("export/"+filename, []byte(newContent), 0644)
+	m.pageTrees[rootPageId] = links
+	return links, nil
 }
 
-func processCodeBlocksInHTMLFile(filename string) error {
-	content, err := os.ReadFile("export/" + filename)
+// exportAndTransform fetches page in the configured source format and runs
+// pipeline on it.
+func exportAndTransform(confluenceClient *confluence.ConfluenceExtendedClient, page *cf.Content, spaceKey string, conversion conversionOptions, logger *slog.Logger, pipeline transform.Pipeline) (*html.Node, error) {
+	doc, err := pageDocument(confluenceClient, page, spaceKey, conversion, logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if err := pipeline.Run(doc); err != nil {
+		return nil, fmt.Errorf("failed to transform page %s (%s): %w", page.ID, page.Title, err)
+	}
+	return doc, nil
+}
 
-	html := string(content)
-
-	// Match Confluence code panel: <div class="code panel...">...<pre class="syntaxhighlighter-pre" data-syntaxhighlighter-params="brush: LANG; ...">CODE</pre>...</div></div>
-	codePanelRegex := regexp.MustCompile(`(?s)<div[^>]+class="[^"]*code panel[^"]*"[^>]*>.*?<pre[^>]*data-syntaxhighlighter-params="brush:\s*([^;"\s]+)[^"]*"[^>]*>(.*?)</pre>.*?</div>\s*</div>`)
-	brushLangRegex := regexp.MustCompile(`brush:\s*([^;"\s]+)`)
-
-	html = codePanelRegex.ReplaceAllStringFunc(html, func(match string) string {
-		lang := ""
-		if m := brushLangRegex.FindStringSubmatch(match); len(m) > 1 {
-			lang = m[1]
+// pageDocument returns page as an HTML document, either its export view or
+// its storage format or ADF body converted to the same shape.
+func pageDocument(confluenceClient *confluence.ConfluenceExtendedClient, page *cf.Content, spaceKey string, conversion conversionOptions, logger *slog.Logger) (*html.Node, error) {
+	switch conversion.sourceFormat {
+	case sourceFormatStorage:
+		if page.Body == nil || page.Body.StorageView == nil {
+			return nil, fmt.Errorf("page %s (%s) was fetched without its storage format", page.ID, page.Title)
 		}
-		// Extract code content between <pre ...> and </pre>
-		preRegex := regexp.MustCompile(`(?s)<pre[^>]*>(.*?)</pre>`)
-		preMatch := preRegex.FindStringSubmatch(match)
-		if len(preMatch) < 2 {
-			return match
+		converter := transform.StorageConverter{
+			ConfluenceBaseURL: confluenceClient.GetBaseURL(),
+			PageID:            page.ID,
+			SpaceKey:          spaceKey,
+			PageURL:           confluenceDisplayURL,
 		}
-		code := preMatch[1]
-		if lang != "" {
-			return `<pre><code class="language-` + lang + `">` + code + `</code></pre>`
+		doc, err := converter.Convert(page.Title, page.Body.StorageView.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse storage format of page %s (%s): %w", page.ID, page.Title, err)
 		}
-		return `<pre><code>` + code + `</code></pre>`
-	})
+		return doc, nil
+	case sourceFormatADF:
+		adf, err := confluenceClient.AtlasDocFormat(page.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch ADF of page %s (%s): %w", page.ID, page.Title, err)
+		}
+		converter := transform.ADFConverter{
+			ConfluenceBaseURL: confluenceClient.GetBaseURL(),
+			PageID:            page.ID,
+			Logger:            logger,
+		}
+		doc, err := converter.Convert(page.Title, adf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert ADF of page %s (%s): %w", page.ID, page.Title, err)
+		}
+		return doc, nil
+	}
 
-	return os.WriteFile("export/"+filename, []byte(html), 0644)
+	htmlContent, err := confluenceClient.ExportHTML(page.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export page %s (%s): %w", page.ID, page.Title, err)
+	}
+	doc, err := transform.Parse(htmlContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse exported page %s (%s): %w", page.ID, page.Title, err)
+	}
+	return doc, nil
+}
+
+// writeExportFile renders doc to export/<pageId>.html, where ImportDocument
+// reads it from, and returns the file name.
+func writeExportFile(pageId string, doc *html.Node) (*string, error) {
+	filename := pageId + ".html"
+	htmlContent, err := transform.Render(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll("export", 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile("export/"+filename, []byte(htmlContent), 0644); err != nil {
+		return nil, err
+	}
+	return &filename, nil
 }
 
 func (m Migrator) migratePageRecurse(page *cf.Content, parentDocumentId string) error {
-	exportedDoc, err := m.confluenceClient.ExportDoc(page.ID)
+	restricted, err := m.viewRestricted(page)
 	if err != nil {
-		return fmt.Errorf("failed to export page %s (%s): %w", page.ID, page.Title, err)
+		return err
 	}
-	if err := m.processImagesInHTMLFile(*exportedDoc); err != nil {
-		m.logger.Warn("Failed to process images", "pageId", page.ID, "pageTitle", page.Title, "error", err)
+	if restricted {
+		restrictedMigrator, parentDocumentId := m.restrictedMigrator(page, parentDocumentId)
+		if restrictedMigrator == nil {
+			return nil
+		}
+		return restrictedMigrator.migratePageRecurse(page, parentDocumentId)
+	}
+
+	doc, err := exportAndTransform(m.confluenceClient, page, m.spaceKey, m.conversion, m.logger, m.pagePipeline(page))
+	if err != nil {
+		return err
 	}
-	if err := processCodeBlocksInHTMLFile(*exportedDoc); err != nil {
-		m.logger.Warn("Failed to process code blocks", "pageId", page.ID, "pageTitle", page.Title, "error", err)
+	exportedDoc, err := writeExportFile(page.ID, doc)
+	if err != nil {
+		return fmt.Errorf("failed to write exported page %s (%s): %w", page.ID, page.Title, err)
 	}
 	importDocumentRes, err := m.importDocumentExportedFromOutline(page, parentDocumentId, exportedDoc)
 	if err != nil {
@@ -420,55 +751,76 @@ func (m Migrator) migratePageRecurse(page *cf.Content, parentDocumentId string)
 	if importDocumentRes.JSON200 == nil {
 		return fmt.Errorf("import failed for page %s (%s): status %d body: %s", page.ID, page.Title, importDocumentRes.StatusCode(), string(importDocumentRes.Body))
 	}
-	m.createPageMapping(page, importDocumentRes)
+	m.createPageMapping(page, importDocumentRes.JSON200.Data)
 
 	createdDocumentId := *importDocumentRes.JSON200.Data.Id
+	if m.unpublished {
+		m.unpublishedDocs[createdDocumentId.String()] = true
+	}
 	m.logger.Info("Imported document", "documentId", createdDocumentId, "documentTitle", *importDocumentRes.JSON200.Data.Title)
 
-	if page.Children == nil || page.Children.Pages.Size == 0 {
+	childPages, err := m.selector.children(page)
+	if err != nil {
+		return err
+	}
+	if len(childPages) == 0 {
 		return nil
 	}
-	m.logger.Info("Migrating child pages", "childPageCount", page.Children.Pages.Size, "pageId", page.ID, "pageTitle", page.Title)
+	m.logger.Info("Migrating child pages", "childPageCount", len(childPages), "pageId", page.ID, "pageTitle", page.Title)
 
 	// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves Confluence order.
-	for i := len(page.Children.Pages.Results) - 1; i >= 0; i-- {
-		childPage := page.Children.Pages.Results[i]
-
-		childPageFull, err := m.confluenceClient.Client.GetContentByID(childPage.ID, cf.ContentIDParameters{
-			Expand: []string{"version", "body.storage", "children.page"},
-		})
-		if err != nil {
-			return fmt.Errorf("failed to get child page %s: %w", childPage.ID, err)
-		}
-		if err := m.migratePageRecurse(childPageFull, createdDocumentId.String()); err != nil {
+	for i := len(childPages) - 1; i >= 0; i-- {
+		if err := m.migratePageRecurse(childPages[i], createdDocumentId.String()); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *Migrator) createPageMapping(page *cf.Content, importDocumentRes *outline.PostDocumentsImportResponse) {
-	createdDocumentId := *importDocumentRes.JSON200.Data.Id
-	title := *importDocumentRes.JSON200.Data.Title
-	urlId := *importDocumentRes.JSON200.Data.UrlId
+func (m *Migrator) createPageMapping(page *cf.Content, document *outline.Document) {
+	createdDocumentId := *document.Id
+	title := *document.Title
+	urlId := *document.UrlId
 	titleSlug := slug.Make(title) // Slug is not present for input document response
 	destOutlineUrl := fmt.Sprintf(`/doc/%s-%s`, titleSlug, urlId)
 	confluenceURLs := m.getPossibleConfluenceURLs(page)
 	for i := range confluenceURLs {
 		m.urlMap = updateUrlMap(m.urlMap, confluenceURLs[i], destOutlineUrl, createdDocumentId.String())
 	}
+	m.indexLabels(page, labelledDocument{title: title, url: destOutlineUrl})
 }
 
 func (m Migrator) getPossibleConfluenceURLs(page *cf.Content) []string {
+	if post, ok := m.blogPosts[page.ID]; ok {
+		return blogPostURLs(m.spaceKey, post)
+	}
+	return possibleConfluenceURLs(m.spaceKey, page)
+}
+
+// possibleConfluenceURLs lists the relative URLs under which Confluence links
+// to page: the pageId form and both title-encoded /display/ forms.
+func possibleConfluenceURLs(spaceKey string, page *cf.Content) []string {
 	var urls []string
-	urls = append(urls, fmt.Sprintf(`/pages/viewpage.action?pageId=%s`, page.ID))
+	urls = append(urls, confluencePageURL(page.ID))
 	encodedTitle := strings.ReplaceAll(page.Title, ":", "%3A")
-	urls = append(urls, fmt.Sprintf(`/display/%s/%s`, m.spaceKey, encodedTitle))
-	encodedTitle = strings.ReplaceAll(encodedTitle, " ", "+")
-	urls = append(urls, fmt.Sprintf(`/display/%s/%s`, m.spaceKey, encodedTitle))
+	urls = append(urls, fmt.Sprintf(`/display/%s/%s`, spaceKey, encodedTitle))
+	urls = append(urls, confluenceDisplayURL(spaceKey, page.Title))
 	return urls
 }
 
+// confluenceDisplayURL returns the relative /display/ URL of the page titled
+// title in the space, in the "+"-encoded form Confluence itself links with.
+func confluenceDisplayURL(spaceKey, title string) string {
+	encodedTitle := strings.ReplaceAll(strings.ReplaceAll(title, ":", "%3A"), " ", "+")
+	return fmt.Sprintf(`/display/%s/%s`, spaceKey, encodedTitle)
+}
+
+// confluencePageURL returns the relative Confluence URL of the page with the
+// given id.
+func confluencePageURL(pageId string) string {
+	return "/pages/viewpage.action?pageId=" + pageId
+}
+
 func updateUrlMap(urlMap map[string]UrlMapEntry, oldUrl, newUrl, createdDocumentId string) map[string]UrlMapEntry {
 	urlMap[oldUrl] = UrlMapEntry{NewUrl: newUrl, DocId: createdDocumentId}
 	return urlMap
@@ -495,8 +847,19 @@ func init() {
 	rootCmd.AddCommand(migrateCmd)
 	migrateCmd.PersistentFlags().String("from", "", "Confluence SpaceKey to migrate pages from")
 	migrateCmd.MarkPersistentFlagRequired("from")
-	migrateCmd.PersistentFlags().String("to", "", "Outline collection id to import documents into")
-	migrateCmd.MarkPersistentFlagRequired("to")
+	migrateCmd.PersistentFlags().String("to", "", "Outline collection id to import documents into. Required unless --output-zip is set.")
+	migrateCmd.PersistentFlags().String("output-zip", "", "Write an Outline Markdown import zip to this file instead of importing through the API.")
+	migrateCmd.PersistentFlags().Bool("repair-links", true, "Repair links that the import split across list items and list every repair in repairedLinks.json. Set to false to only report them in checkURLs.json.")
+	migrateCmd.PersistentFlags().Bool("two-phase", false, "Create placeholder documents for the whole tree first and rewrite links before writing each document, instead of fixing links after import.")
+	migrateCmd.PersistentFlags().String("parent-document", "", "Id of an Outline document in the --to collection to import the top-level pages below, instead of the top of the collection.")
+	migrateCmd.PersistentFlags().Bool("blog-posts", false, "Also migrate the blog posts of the space, below a Blog document organised by year and month.")
+	migrateCmd.PersistentFlags().Bool("label-index", false, "Create a document per Confluence label below a Labels document, listing the pages with the label, and list the labels of each page below its title.")
+	migrateCmd.PersistentFlags().Bool("templates", false, "Also convert the page and blueprint templates of the space into templates of the --to collection.")
+	migrateCmd.PersistentFlags().String("home-page", homePageDocument, "What to do with the home page of the space: import it as a document, or use it as the overview of the --to collection with the pages below it moved to the top of the collection.")
+	migrateCmd.PersistentFlags().String("blog-collection", "", "Id of an Outline collection to migrate blog posts into instead of a Blog document of the --to collection.")
+	addConversionFlags(migrateCmd)
+	addSelectionFlags(migrateCmd)
+	addPagePolicyFlags(migrateCmd)
 	migrateCmd.PersistentFlags().String("mark", "", "Regex pattern within pages to review later. List of pages matching regex are saved in a Marked.json file for manual review.")
 
 }
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	cf "github.com/essentialkaos/go-confluence/v6"
+
+	"github.com/oskarspakers/confluence-to-outline/transform"
 )
 
 func TestUpdateUrlMap(t *testing.T) {
@@ -157,3 +159,55 @@ func TestAddToMarkedListIgnoresUnknownDoc(t *testing.T) {
 		t.Errorf("expected nil, got %+v", marked)
 	}
 }
+
+func TestIncludesTransformerLinksOtherSpacesToConfluence(t *testing.T) {
+	page := &cf.Content{ID: "1", Title: "Runbook"}
+	report := &migrationReport{}
+	conversion := conversionOptions{sourceFormat: sourceFormatExport, includeMode: transform.IncludeLink}
+	includes := includesTransformer(page, "ENG", "https://example.atlassian.net/wiki/", conversion, report, nil)
+
+	tests := []struct {
+		ref  transform.IncludeReference
+		want string
+	}{
+		{transform.IncludeReference{Title: "Shared steps"}, "/display/ENG/Shared+steps"},
+		{transform.IncludeReference{Title: "Shared steps", SpaceKey: "ENG"}, "/display/ENG/Shared+steps"},
+		{transform.IncludeReference{Title: "On-call: rota", SpaceKey: "OPS"}, "https://example.atlassian.net/wiki/display/OPS/On-call%3A+rota"},
+	}
+	for _, tt := range tests {
+		if got := includes.PageURL(tt.ref); got != tt.want {
+			t.Errorf("PageURL(%+v) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+	want := []ReportEntry{{PageId: "1", Title: "Runbook", Issue: `Page "On-call: rota" included from space OPS, linked to Confluence`}}
+	if !reflect.DeepEqual(report.entries, want) {
+		t.Errorf("report = %+v, want %+v", report.entries, want)
+	}
+}
+
+func TestIncludesTransformerLinksWithoutRenderedContent(t *testing.T) {
+	page := &cf.Content{ID: "1", Title: "Runbook"}
+	tests := []struct {
+		sourceFormat string
+		includeMode  string
+		wantMode     string
+		wantReport   []ReportEntry
+	}{
+		{sourceFormatExport, transform.IncludeInline, transform.IncludeInline, nil},
+		{sourceFormatStorage, transform.IncludeInline, transform.IncludeLink,
+			[]ReportEntry{{PageId: "1", Title: "Runbook", Issue: `Page "Shared steps" included as a link, as the storage format holds no included content`}}},
+		{sourceFormatADF, transform.IncludeLink, transform.IncludeLink, nil},
+	}
+	for _, tt := range tests {
+		report := &migrationReport{}
+		conversion := conversionOptions{sourceFormat: tt.sourceFormat, includeMode: tt.includeMode}
+		includes := includesTransformer(page, "ENG", "https://example.atlassian.net/wiki/", conversion, report, nil)
+		if includes.Mode != tt.wantMode {
+			t.Errorf("%s/%s: Mode = %q, want %q", tt.sourceFormat, tt.includeMode, includes.Mode, tt.wantMode)
+		}
+		includes.PageURL(transform.IncludeReference{Title: "Shared steps"})
+		if !reflect.DeepEqual(report.entries, tt.wantReport) {
+			t.Errorf("%s/%s: report = %+v, want %+v", tt.sourceFormat, tt.includeMode, report.entries, tt.wantReport)
+		}
+	}
+}
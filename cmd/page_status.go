@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/outline"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// What happens to archived pages.
+const (
+	archivedSkip    = "skip"
+	archivedArchive = "archive"
+	archivedSubtree = "subtree"
+)
+
+// What happens to draft pages.
+const (
+	draftsSkip  = "skip"
+	draftsDraft = "draft"
+)
+
+// What happens to view-restricted pages and the pages below them.
+const (
+	restrictedPublish     = "publish"
+	restrictedSkip        = "skip"
+	restrictedUnpublished = "unpublished"
+	restrictedCollection  = "collection"
+)
+
+// archiveTitle is the title of the document archived pages are imported
+// below with --archived-pages subtree.
+const archiveTitle = "Archive"
+
+// pagePolicies are the flags deciding what migrate does with archived,
+// draft and view-restricted pages.
+type pagePolicies struct {
+	archived               string
+	drafts                 string
+	restricted             string
+	restrictedCollectionId string
+}
+
+// isDefault reports whether the policies keep the behaviour of --output-zip,
+// which can neither archive documents nor leave them unpublished.
+func (p pagePolicies) isDefault() bool {
+	return p.archived == archivedSkip && p.drafts == draftsSkip && p.restricted == restrictedPublish
+}
+
+func pagePoliciesFromFlags(cmd *cobra.Command) (pagePolicies, error) {
+	archived, err := cmd.Flags().GetString("archived-pages")
+	if err != nil {
+		return pagePolicies{}, fmt.Errorf("Error getting --archived-pages flag: %w", err)
+	}
+	if archived != archivedSkip && archived != archivedArchive && archived != archivedSubtree {
+		return pagePolicies{}, fmt.Errorf("invalid --archived-pages %q: must be %s, %s or %s", archived, archivedSkip, archivedArchive, archivedSubtree)
+	}
+	drafts, err := cmd.Flags().GetString("draft-pages")
+	if err != nil {
+		return pagePolicies{}, fmt.Errorf("Error getting --draft-pages flag: %w", err)
+	}
+	if drafts != draftsSkip && drafts != draftsDraft {
+		return pagePolicies{}, fmt.Errorf("invalid --draft-pages %q: must be %s or %s", drafts, draftsSkip, draftsDraft)
+	}
+	restricted, err := cmd.Flags().GetString("restricted-pages")
+	if err != nil {
+		return pagePolicies{}, fmt.Errorf("Error getting --restricted-pages flag: %w", err)
+	}
+	if restricted != restrictedPublish && restricted != restrictedSkip && restricted != restrictedUnpublished && restricted != restrictedCollection {
+		return pagePolicies{}, fmt.Errorf("invalid --restricted-pages %q: must be %s, %s, %s or %s", restricted, restrictedPublish, restrictedSkip, restrictedUnpublished, restrictedCollection)
+	}
+	restrictedCollectionId, err := cmd.Flags().GetString("restricted-collection")
+	if err != nil {
+		return pagePolicies{}, fmt.Errorf("Error getting --restricted-collection flag: %w", err)
+	}
+	if restricted == restrictedCollection {
+		if _, err := uuid.Parse(restrictedCollectionId); err != nil {
+			return pagePolicies{}, fmt.Errorf("invalid --restricted-collection %q: --restricted-pages collection needs a collection id", restrictedCollectionId)
+		}
+	}
+	return pagePolicies{
+		archived:               archived,
+		drafts:                 drafts,
+		restricted:             restricted,
+		restrictedCollectionId: restrictedCollectionId,
+	}, nil
+}
+
+// addPagePolicyFlags registers the flags read by pagePoliciesFromFlags.
+func addPagePolicyFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("archived-pages", archivedSkip, "What to do with archived pages: skip them, archive imports them below their parent and archives them in Outline, subtree imports them below an Archive document.")
+	cmd.PersistentFlags().String("draft-pages", draftsSkip, "What to do with draft pages: skip them, or draft imports them as unpublished Outline drafts.")
+	cmd.PersistentFlags().String("restricted-pages", restrictedPublish, "What to do with pages only some users may view, and the pages below them: publish them like any other page, skip them, import them unpublished, or import them into the --restricted-collection.")
+	cmd.PersistentFlags().String("restricted-collection", "", "Id of the Outline collection view-restricted pages are imported into with --restricted-pages collection.")
+}
+
+// viewRestricted reports whether viewing page is restricted, unless it is
+// below a restricted page, whose migrator already applied the policy, or
+// restricted pages are published like any other, in which case the
+// restrictions are not looked up.
+func (m *Migrator) viewRestricted(page *cf.Content) (bool, error) {
+	if m.restricted || m.policies.restricted == restrictedPublish {
+		return false, nil
+	}
+	restricted, err := m.confluenceClient.ReadRestricted(page.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get restrictions of page %s (%s): %w", page.ID, page.Title, err)
+	}
+	return restricted, nil
+}
+
+// restrictedMigrator returns the migrator that migrates the view-restricted
+// page and the pages below it per the restricted-pages policy, and the
+// document to import page below, or nil when they are skipped.
+func (m *Migrator) restrictedMigrator(page *cf.Content, parentDocumentId string) (*Migrator, string) {
+	restrictedMigrator := *m
+	restrictedMigrator.restricted = true
+	switch m.policies.restricted {
+	case restrictedSkip:
+		m.logger.Info("Skipping view-restricted page and the pages below it", "pageId", page.ID, "pageTitle", page.Title)
+		m.report.add(page, "view-restricted page skipped with the pages below it")
+		return nil, ""
+	case restrictedUnpublished:
+		restrictedMigrator.unpublished = true
+		m.report.add(page, "view-restricted page imported unpublished with the pages below it")
+	case restrictedCollection:
+		restrictedMigrator.collectionId = m.policies.restrictedCollectionId
+		parentDocumentId = ""
+		m.report.add(page, "view-restricted page imported into the restricted collection with the pages below it")
+	}
+	return &restrictedMigrator, parentDocumentId
+}
+
+// migrateStatusPages migrates the archived and draft pages of the space per
+// their policies with migrate, after the page tree, so that they can be
+// placed below the documents of their parents. Every page is listed in the
+// report.
+func (m *Migrator) migrateStatusPages(migrate func(m *Migrator, page *cf.Content, parentDocumentId string) error) error {
+	archivedPages, err := m.selector.pagesByStatus(confluence.StatusArchived)
+	if err != nil {
+		return err
+	}
+	archivedDocIds := make(map[string]string)
+	archiveDocumentId := ""
+	for _, page := range archivedPages {
+		switch m.policies.archived {
+		case archivedSkip:
+			m.report.add(page, "archived page skipped")
+			continue
+		case archivedArchive:
+			parentDocumentId, ok := ancestorDocument(page, m.migratedDocument)
+			if !ok {
+				parentDocumentId = m.parentDocumentId
+			}
+			if err := migrate(m, page, parentDocumentId); err != nil {
+				return err
+			}
+			if docId, ok := m.migratedDocument(page.ID); ok {
+				*m.archiveDocIds = append(*m.archiveDocIds, docId)
+			}
+			m.report.add(page, "archived page imported and archived")
+		case archivedSubtree:
+			if archiveDocumentId == "" {
+				archiveDocumentId, err = m.createContainerDocument(m.collectionId, archiveTitle, m.parentDocumentId)
+				if err != nil {
+					return err
+				}
+			}
+			parentDocumentId, ok := ancestorDocument(page, func(pageId string) (string, bool) {
+				docId, ok := archivedDocIds[pageId]
+				return docId, ok
+			})
+			if !ok {
+				parentDocumentId = archiveDocumentId
+			}
+			if err := migrate(m, page, parentDocumentId); err != nil {
+				return err
+			}
+			if docId, ok := m.migratedDocument(page.ID); ok {
+				archivedDocIds[page.ID] = docId
+			}
+			m.report.add(page, "archived page imported below the Archive document")
+		}
+	}
+
+	draftPages, err := m.selector.pagesByStatus(confluence.StatusDraft)
+	if err != nil {
+		return err
+	}
+	for _, page := range draftPages {
+		if m.policies.drafts == draftsSkip {
+			m.report.add(page, "draft page skipped")
+			continue
+		}
+		parentDocumentId, ok := ancestorDocument(page, m.migratedDocument)
+		if !ok {
+			parentDocumentId = m.parentDocumentId
+		}
+		draftMigrator := *m
+		draftMigrator.unpublished = true
+		if err := migrate(&draftMigrator, page, parentDocumentId); err != nil {
+			return err
+		}
+		m.report.add(page, "draft page imported as an Outline draft")
+	}
+	return nil
+}
+
+// migratedDocument returns the id of the document the page with the given
+// id was migrated to.
+func (m *Migrator) migratedDocument(pageId string) (string, bool) {
+	urlMapEntry, ok := m.urlMap[confluencePageURL(pageId)]
+	if ok && urlMapEntry.DocId == "" {
+		// The home page became the collection overview, and the pages below
+		// it are at the top of the migrated tree.
+		return m.parentDocumentId, true
+	}
+	return urlMapEntry.DocId, ok
+}
+
+// ancestorDocument returns the document of the closest ancestor of page that
+// document finds one for.
+func ancestorDocument(page *cf.Content, document func(pageId string) (string, bool)) (string, bool) {
+	for i := len(page.Ancestors) - 1; i >= 0; i-- {
+		if docId, ok := document(page.Ancestors[i].ID); ok {
+			return docId, true
+		}
+	}
+	return "", false
+}
+
+// archiveDocuments archives the documents of archived pages, once their
+// content and links have been written.
+func (m *Migrator) archiveDocuments() {
+	for _, docId := range *m.archiveDocIds {
+		resp, err := m.outlineClient.Client.PostDocumentsArchiveWithResponse(context.Background(), outline.PostDocumentsArchiveJSONRequestBody{
+			Id: docId,
+		})
+		if err != nil {
+			m.logger.Error("Failed to archive Outline document", "documentId", docId, "error", err)
+			continue
+		}
+		if resp.JSON200 == nil {
+			m.logger.Error("Failed to archive Outline document", "documentId", docId, "status", resp.StatusCode(), "body", string(resp.Body))
+			continue
+		}
+		m.logger.Info("Archived document", "documentId", docId)
+	}
+}
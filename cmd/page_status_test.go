@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+func TestAncestorDocument(t *testing.T) {
+	page := &cf.Content{ID: "4", Ancestors: []*cf.Content{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+	documents := map[string]string{"1": "doc-1", "2": "doc-2"}
+	document := func(pageId string) (string, bool) {
+		docId, ok := documents[pageId]
+		return docId, ok
+	}
+
+	if docId, ok := ancestorDocument(page, document); !ok || docId != "doc-2" {
+		t.Errorf("ancestorDocument() = %q, %v, want the closest migrated ancestor doc-2", docId, ok)
+	}
+	if docId, ok := ancestorDocument(&cf.Content{ID: "1"}, document); ok {
+		t.Errorf("ancestorDocument() of a root page = %q, want none", docId)
+	}
+}
+
+func TestViewRestrictedSkipsLookupWhenPublishing(t *testing.T) {
+	// Without a Confluence client, a lookup would panic.
+	m := &Migrator{policies: pagePolicies{restricted: restrictedPublish}}
+	if restricted, err := m.viewRestricted(&cf.Content{ID: "1"}); restricted || err != nil {
+		t.Errorf("viewRestricted() = %v, %v, want false, nil", restricted, err)
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/jira"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+// pipelineOptions is what the page pipeline shared by migrate and
+// export-markdown needs.
+type pipelineOptions struct {
+	confluenceClient *confluence.ConfluenceExtendedClient
+	jiraClient       *jira.Client
+	spaceKey         string
+	conversion       conversionOptions
+	// store stores images, diagrams, attachments and table renderings and
+	// returns the URL to link them by.
+	store func(data []byte, filename string, contentType string) (string, error)
+	// pageTree lists the pages below a page for children and pagetree
+	// macros.
+	pageTree func(rootPageId string) ([]transform.PageLink, error)
+	report   *migrationReport
+	logger   *slog.Logger
+}
+
+// pagePipeline returns the transformations applied to page after export,
+// before the steps particular to each command.
+func pagePipeline(page *cf.Content, opts pipelineOptions) transform.Pipeline {
+	confluenceBaseURL := opts.confluenceClient.GetBaseURL()
+	return transform.Pipeline{
+		includesTransformer(page, opts.spaceKey, confluenceBaseURL, opts.conversion, opts.report, opts.logger),
+		jiraTransformer(page, opts.jiraClient, opts.logger),
+		transform.Tasks{},
+		transform.StatusLozenges{},
+		// Emoticons must run before Images, which would re-upload the icons.
+		transform.Emoticons{},
+		diagramsTransformer(page, opts.confluenceClient, opts.store, opts.conversion, opts.logger),
+		mathTransformer(page, opts.logger),
+		transform.Images{
+			ConfluenceBaseURL: confluenceBaseURL,
+			Download:          opts.confluenceClient.DownloadImage,
+			Store:             opts.store,
+			Logger:            opts.logger,
+		},
+		transform.Attachments{
+			ConfluenceBaseURL: confluenceBaseURL,
+			Download:          opts.confluenceClient.DownloadImage,
+			Store:             opts.store,
+			Logger:            opts.logger,
+		},
+		transform.CodeBlocks{},
+		transform.Notices{},
+		transform.Macros{
+			PageID:        page.ID,
+			RegenerateTOC: opts.conversion.regenerateTOC,
+			PageTree:      opts.pageTree,
+			Report: func(issue string) {
+				opts.report.add(page, issue)
+			},
+			Logger: opts.logger,
+		},
+		transform.Layout{Separators: opts.conversion.layoutSeparators},
+		tablesTransformer(page, opts.store, opts.conversion, opts.report, opts.logger),
+	}
+}
+
+// The steps below pair macros in the export view with the same macros in
+// the storage format of the page, which holds what the export view lost.
+
+// mathTransformer returns the Math step for page.
+func mathTransformer(page *cf.Content, logger *slog.Logger) transform.Math {
+	math := transform.Math{Logger: logger}
+	if page.Body == nil || page.Body.StorageView == nil {
+		return math
+	}
+	refs, err := transform.ParseMathReferences(page.Body.StorageView.Value)
+	if err != nil {
+		logger.Warn("Failed to parse storage format, math macros are kept as exported", "pageId", page.ID, "pageTitle", page.Title, "error", err)
+		return math
+	}
+	math.References = refs
+	return math
+}
+
+// tablesTransformer returns the Tables transformer for page, listing the
+// complex tables it rewrites in report.
+func tablesTransformer(page *cf.Content, store func(data []byte, filename string, contentType string) (string, error), conversion conversionOptions, report *migrationReport, logger *slog.Logger) transform.Tables {
+	return transform.Tables{
+		Mode:  conversion.complexTables,
+		Store: store,
+		Report: func(issue string) {
+			report.add(page, issue)
+		},
+		Logger: logger,
+	}
+}
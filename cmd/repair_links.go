@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+)
+
+// brokenLinkRegex matches the `[text\n](URL)` shape that bodyHasBrokenLink
+// reports: Confluence's exporter wraps an auto-numbered list item in a link,
+// and the import turns it into a link whose text spans the list item.
+var brokenLinkRegex = regexp.MustCompile(`\[([^\[\]]*)\n\]\(([^()\s]+)\)`)
+
+// listMarkerRegex matches a Markdown list marker at the start of link text.
+var listMarkerRegex = regexp.MustCompile(`^(\d+[.)]|[-*+])\s+`)
+
+type LinkRepair struct {
+	Counter int
+	Id      string `json:"DocumentID"`
+	Title   string `json:"DocumentTitle"`
+	Target  string `json:"LinkTarget"`
+	Before  string
+	After   string
+}
+
+// repairBrokenLinks rewrites every broken link in documentBody into a
+// well-formed Markdown link. A list marker found inside the link text is
+// moved in front of the link and starts a new line so the list item
+// survives, and whitespace inside the text is collapsed. Links without a list
+// marker stay where they are in the prose. The returned repairs carry
+// the before and after snippets; their document fields are left empty.
+func repairBrokenLinks(documentBody string) (string, []LinkRepair) {
+	matches := brokenLinkRegex.FindAllStringSubmatchIndex(documentBody, -1)
+	if len(matches) == 0 {
+		return documentBody, nil
+	}
+
+	var repairs []LinkRepair
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		text := documentBody[match[2]:match[3]]
+		target := documentBody[match[4]:match[5]]
+
+		text = strings.Join(strings.Fields(text), " ")
+		marker := ""
+		if loc := listMarkerRegex.FindStringSubmatchIndex(text); loc != nil {
+			marker = text[loc[2]:loc[3]] + " "
+			text = text[loc[1]:]
+		}
+		if text == "" {
+			text = target
+		}
+
+		repaired := marker + "[" + text + "](" + target + ")"
+		b.WriteString(documentBody[last:start])
+		if marker != "" && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(repaired)
+		last = end
+		repairs = append(repairs, LinkRepair{
+			Target: target,
+			Before: documentBody[start:end],
+			After:  strings.TrimSpace(repaired),
+		})
+	}
+	b.WriteString(documentBody[last:])
+	return b.String(), repairs
+}
+
+func outputLinkRepairs(data []LinkRepair, filename string) {
+	for i := range data {
+		data[i].Counter = i + 1
+	}
+	outputDataToJSON(data, filename)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepairBrokenLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		repairs []LinkRepair
+	}{
+		{
+			name: "consecutive list items are split into separate lines",
+			body: "Steps:\n[\n1. Install\n](/doc/install-abc)[\n2. Configure it\n](/doc/configure-def)\nDone",
+			want: "Steps:\n1. [Install](/doc/install-abc)\n2. [Configure it](/doc/configure-def)\nDone",
+			repairs: []LinkRepair{
+				{Target: "/doc/install-abc", Before: "[\n1. Install\n](/doc/install-abc)", After: "1. [Install](/doc/install-abc)"},
+				{Target: "/doc/configure-def", Before: "[\n2. Configure it\n](/doc/configure-def)", After: "2. [Configure it](/doc/configure-def)"},
+			},
+		},
+		{
+			name: "link without list marker stays in the prose",
+			body: "See [Home\n](https://outline.example.com/doc/home-abc) now",
+			want: "See [Home](https://outline.example.com/doc/home-abc) now",
+			repairs: []LinkRepair{
+				{Target: "https://outline.example.com/doc/home-abc", Before: "[Home\n](https://outline.example.com/doc/home-abc)", After: "[Home](https://outline.example.com/doc/home-abc)"},
+			},
+		},
+		{
+			name: "links in a sentence stay on one line",
+			body: "See [Home\n](/doc/home-abc)[About\n](/doc/about-def) now",
+			want: "See [Home](/doc/home-abc)[About](/doc/about-def) now",
+			repairs: []LinkRepair{
+				{Target: "/doc/home-abc", Before: "[Home\n](/doc/home-abc)", After: "[Home](/doc/home-abc)"},
+				{Target: "/doc/about-def", Before: "[About\n](/doc/about-def)", After: "[About](/doc/about-def)"},
+			},
+		},
+		{
+			name: "empty link text falls back to the target",
+			body: "[\n](/doc/home-abc)",
+			want: "[/doc/home-abc](/doc/home-abc)",
+			repairs: []LinkRepair{
+				{Target: "/doc/home-abc", Before: "[\n](/doc/home-abc)", After: "[/doc/home-abc](/doc/home-abc)"},
+			},
+		},
+		{
+			name: "well-formed links are untouched",
+			body: "See [Home](/doc/home-abc) here.",
+			want: "See [Home](/doc/home-abc) here.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repairs := repairBrokenLinks(tt.body)
+			if got != tt.want {
+				t.Errorf("repairBrokenLinks() body = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(repairs, tt.repairs) {
+				t.Errorf("repairBrokenLinks() repairs = %+v, want %+v", repairs, tt.repairs)
+			}
+			if bodyHasBrokenLink(got, UrlMapEntry{NewUrl: "/doc/home-abc"}, "https://outline.example.com") {
+				t.Errorf("repaired body still has a broken link: %q", got)
+			}
+		})
+	}
+}
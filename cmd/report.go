@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+// ReportEntry is a page whose content could not be converted faithfully and
+// should be reviewed after the migration.
+type ReportEntry struct {
+	Counter int
+	PageId  string `json:"ConfluencePageID"`
+	Title   string `json:"ConfluencePageTitle"`
+	Issue   string
+}
+
+// migrationReport collects the pages that need review and is written to
+// migrationReport.json once the migration is done.
+type migrationReport struct {
+	entries []ReportEntry
+}
+
+func (r *migrationReport) add(page *cf.Content, issue string) {
+	r.entries = append(r.entries, ReportEntry{PageId: page.ID, Title: page.Title, Issue: issue})
+}
+
+func (r *migrationReport) output() {
+	for i := range r.entries {
+		r.entries[i].Counter = i + 1
+	}
+	outputDataToJSON(r.entries, "migrationReport")
+}
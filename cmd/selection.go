@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/spf13/cobra"
+)
+
+// What happens to the selected pages below a page that is not selected.
+const (
+	excludedParentsReparent = "reparent"
+	excludedParentsSkip     = "skip"
+)
+
+// selectionOptions are the flags choosing which pages of a space are
+// migrated that migrate and export-markdown share. Without any, the whole
+// space is.
+type selectionOptions struct {
+	rootPage        string
+	cql             string
+	includeLabels   []string
+	excludeLabels   []string
+	excludeTitle    *regexp.Regexp
+	excludedParents string
+}
+
+func selectionOptionsFromFlags(cmd *cobra.Command) (selectionOptions, error) {
+	rootPage, err := cmd.Flags().GetString("root-page")
+	if err != nil {
+		return selectionOptions{}, fmt.Errorf("Error getting --root-page flag: %w", err)
+	}
+	if _, err := strconv.ParseUint(rootPage, 10, 64); rootPage != "" && err != nil {
+		return selectionOptions{}, fmt.Errorf("invalid --root-page %q: must be a page id", rootPage)
+	}
+	cql, err := cmd.Flags().GetString("cql")
+	if err != nil {
+		return selectionOptions{}, fmt.Errorf("Error getting --cql flag: %w", err)
+	}
+	includeLabels, err := cmd.Flags().GetStringSlice("include-label")
+	if err != nil {
+		return selectionOptions{}, fmt.Errorf("Error getting --include-label flag: %w", err)
+	}
+	excludeLabels, err := cmd.Flags().GetStringSlice("exclude-label")
+	if err != nil {
+		return selectionOptions{}, fmt.Errorf("Error getting --exclude-label flag: %w", err)
+	}
+	excludeTitlePattern, err := cmd.Flags().GetString("exclude-title-regex")
+	if err != nil {
+		return selectionOptions{}, fmt.Errorf("Error getting --exclude-title-regex flag: %w", err)
+	}
+	var excludeTitle *regexp.Regexp
+	if excludeTitlePattern != "" {
+		excludeTitle, err = regexp.Compile(excludeTitlePattern)
+		if err != nil {
+			return selectionOptions{}, fmt.Errorf("invalid --exclude-title-regex %q: %w", excludeTitlePattern, err)
+		}
+	}
+	excludedParents, err := cmd.Flags().GetString("excluded-parents")
+	if err != nil {
+		return selectionOptions{}, fmt.Errorf("Error getting --excluded-parents flag: %w", err)
+	}
+	if excludedParents != excludedParentsReparent && excludedParents != excludedParentsSkip {
+		return selectionOptions{}, fmt.Errorf("invalid --excluded-parents %q: must be %s or %s", excludedParents, excludedParentsReparent, excludedParentsSkip)
+	}
+	return selectionOptions{
+		rootPage:        rootPage,
+		cql:             cql,
+		includeLabels:   includeLabels,
+		excludeLabels:   excludeLabels,
+		excludeTitle:    excludeTitle,
+		excludedParents: excludedParents,
+	}, nil
+}
+
+// addSelectionFlags registers the flags read by selectionOptionsFromFlags.
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("root-page", "", "Id of a Confluence page to migrate with the pages below it, instead of the whole space.")
+	cmd.PersistentFlags().String("cql", "", "Confluence Query Language query selecting the pages to migrate, e.g. 'lastmodified > now(\"-52w\")'. It is limited to the pages of the space.")
+	cmd.PersistentFlags().StringSlice("include-label", nil, "Only migrate pages with one of these labels. Can be repeated or comma-separated.")
+	cmd.PersistentFlags().StringSlice("exclude-label", nil, "Do not migrate pages with one of these labels. Can be repeated or comma-separated.")
+	cmd.PersistentFlags().String("exclude-title-regex", "", "Do not migrate pages whose title matches this regex.")
+	cmd.PersistentFlags().String("excluded-parents", excludedParentsReparent, "What to do with the selected pages below a page that is not selected: reparent moves them up to the closest selected ancestor, skip leaves them out with it.")
+}
+
+// pageSelector walks the page tree chosen by selectionOptions. Pages that
+// are not selected are left out of it; the selected pages below them take
+// their place or are left out too, per the excluded-parents policy.
+type pageSelector struct {
+	options selectionOptions
+	// expand is the v1 expansion every returned page is fetched with.
+	expand  []string
+	getPage func(pageId string, expand []string) (*cf.Content, error)
+	// getRootPages lists the top-level pages of the space.
+	getRootPages func(expand []string) ([]*cf.Content, error)
+	// getBlogPosts lists the blog posts of the space.
+	getBlogPosts func(expand []string) ([]*confluence.BlogPost, error)
+	// getPagesByStatus lists the pages of the space with a status other
+	// than current.
+	getPagesByStatus func(status string, expand []string) ([]*cf.Content, error)
+	// cqlPageIds holds the pages matched by the CQL query, or is nil
+	// without one.
+	cqlPageIds map[string]bool
+	logger     *slog.Logger
+}
+
+func newPageSelector(confluenceClient *confluence.ConfluenceExtendedClient, spaceKey string, options selectionOptions, expand []string, logger *slog.Logger) (*pageSelector, error) {
+	if (len(options.includeLabels) > 0 || len(options.excludeLabels) > 0) && !slices.Contains(expand, "metadata.labels") {
+		expand = append(slices.Clone(expand), "metadata.labels")
+	}
+	selector := &pageSelector{
+		options: options,
+		expand:  expand,
+		getPage: confluenceClient.GetPage,
+		getRootPages: func(expand []string) ([]*cf.Content, error) {
+			return confluenceClient.GetRootPages(spaceKey, expand)
+		},
+		getBlogPosts: func(expand []string) ([]*confluence.BlogPost, error) {
+			return confluenceClient.GetBlogPosts(spaceKey, expand)
+		},
+		getPagesByStatus: func(status string, expand []string) ([]*cf.Content, error) {
+			return confluenceClient.GetPagesByStatus(spaceKey, status, expand)
+		},
+		logger: logger,
+	}
+	if options.cql != "" {
+		pages, err := confluenceClient.SearchPages(fmt.Sprintf("type in (page, blogpost) AND space = %q AND (%s)", spaceKey, options.cql))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search pages with --cql: %w", err)
+		}
+		selector.cqlPageIds = make(map[string]bool)
+		for _, page := range pages {
+			selector.cqlPageIds[page.ID] = true
+		}
+		logger.Info("Selected pages with CQL", "cql", options.cql, "pageCount", len(pages))
+	}
+	return selector, nil
+}
+
+// rootPages returns the selected pages at the top of the migrated tree: the
+// root page, or the top-level pages of the space.
+func (s *pageSelector) rootPages() ([]*cf.Content, error) {
+	if s.options.rootPage == "" {
+		pages, err := s.getRootPages(s.expand)
+		if err != nil {
+			return nil, err
+		}
+		return s.selected(pages)
+	}
+	page, err := s.getPage(s.options.rootPage, s.expand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get root page %s: %w", s.options.rootPage, err)
+	}
+	return s.selected([]*cf.Content{page})
+}
+
+// blogPosts returns the selected blog posts of the space. They are not part
+// of the page tree, so --root-page does not apply to them.
+func (s *pageSelector) blogPosts() ([]*confluence.BlogPost, error) {
+	posts, err := s.getBlogPosts(s.expand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Confluence blog posts: %w", err)
+	}
+	var selected []*confluence.BlogPost
+	for _, post := range posts {
+		if !s.selects(post.Content) {
+			s.logger.Info("Skipping blog post, which is not selected", "pageId", post.ID, "pageTitle", post.Title)
+			continue
+		}
+		selected = append(selected, post)
+	}
+	return selected, nil
+}
+
+// pagesByStatus returns the selected pages with the given status, such as
+// archived pages, which are not part of the page tree. Their ancestors are
+// expanded and parents come before the pages below them.
+func (s *pageSelector) pagesByStatus(status string) ([]*cf.Content, error) {
+	expand := slices.DeleteFunc(slices.Clone(s.expand), func(e string) bool {
+		return e == "children.page"
+	})
+	if !slices.Contains(expand, "ancestors") {
+		expand = append(expand, "ancestors")
+	}
+	pages, err := s.getPagesByStatus(status, expand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s Confluence pages: %w", status, err)
+	}
+	var selected []*cf.Content
+	for _, page := range pages {
+		if s.options.rootPage != "" && page.ID != s.options.rootPage && !slices.ContainsFunc(page.Ancestors, func(ancestor *cf.Content) bool {
+			return ancestor.ID == s.options.rootPage
+		}) {
+			continue
+		}
+		if !s.selects(page) {
+			s.logger.Info("Skipping page, which is not selected", "pageId", page.ID, "pageTitle", page.Title, "status", status)
+			continue
+		}
+		selected = append(selected, page)
+	}
+	slices.SortStableFunc(selected, func(a, b *cf.Content) int {
+		return len(a.Ancestors) - len(b.Ancestors)
+	})
+	return selected, nil
+}
+
+// children returns the selected pages below page.
+func (s *pageSelector) children(page *cf.Content) ([]*cf.Content, error) {
+	if page.Children == nil || page.Children.Pages == nil {
+		return nil, nil
+	}
+	var children []*cf.Content
+	for _, childPage := range page.Children.Pages.Results {
+		childPageFull, err := s.getPage(childPage.ID, s.expand)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get child page %s: %w", childPage.ID, err)
+		}
+		children = append(children, childPageFull)
+	}
+	return s.selected(children)
+}
+
+// selected returns the selected pages of pages in order, with the selected
+// pages below the others in their place when they are reparented.
+func (s *pageSelector) selected(pages []*cf.Content) ([]*cf.Content, error) {
+	var selected []*cf.Content
+	for _, page := range pages {
+		if s.selects(page) {
+			selected = append(selected, page)
+			continue
+		}
+		if s.options.excludedParents == excludedParentsSkip {
+			s.logger.Info("Skipping page and the pages below it, which are not selected", "pageId", page.ID, "pageTitle", page.Title)
+			continue
+		}
+		s.logger.Info("Skipping page, which is not selected", "pageId", page.ID, "pageTitle", page.Title)
+		children, err := s.children(page)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, children...)
+	}
+	return selected, nil
+}
+
+// selects reports whether page matches every selection option.
+func (s *pageSelector) selects(page *cf.Content) bool {
+	if s.cqlPageIds != nil && !s.cqlPageIds[page.ID] {
+		return false
+	}
+	if s.options.excludeTitle != nil && s.options.excludeTitle.MatchString(page.Title) {
+		return false
+	}
+	labels := pageLabels(page)
+	if len(s.options.includeLabels) > 0 && !slices.ContainsFunc(s.options.includeLabels, func(label string) bool {
+		return slices.Contains(labels, label)
+	}) {
+		return false
+	}
+	return !slices.ContainsFunc(s.options.excludeLabels, func(label string) bool {
+		return slices.Contains(labels, label)
+	})
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+// testSpace is a page tree for pageSelector tests:
+//
+//	1 Home
+//	  2 Archive [archived]
+//	    3 Old plan
+//	  4 Team [team]
+//	5 Drafts
+func testSpace() map[string]*cf.Content {
+	page := func(id, title string, labels []string, children ...string) *cf.Content {
+		content := &cf.Content{ID: id, Title: title, Children: &cf.Contents{Pages: &cf.ContentCollection{Size: len(children)}}}
+		for _, child := range children {
+			content.Children.Pages.Results = append(content.Children.Pages.Results, &cf.Content{ID: child})
+		}
+		content.Metadata = &cf.Metadata{Labels: &cf.LabelCollection{}}
+		for _, label := range labels {
+			content.Metadata.Labels.Result = append(content.Metadata.Labels.Result, &cf.Label{Name: label})
+		}
+		return content
+	}
+	return map[string]*cf.Content{
+		"1": page("1", "Home", nil, "2", "4"),
+		"2": page("2", "Archive", []string{"archived"}, "3"),
+		"3": page("3", "Old plan", nil),
+		"4": page("4", "Team", []string{"team"}),
+		"5": page("5", "Drafts", nil),
+	}
+}
+
+// selectedTree returns the titles of the pages the selector walks, children
+// indented below their parents.
+func selectedTree(t *testing.T, options selectionOptions) []string {
+	t.Helper()
+	pages := testSpace()
+	selector := &pageSelector{
+		options: options,
+		getPage: func(pageId string, expand []string) (*cf.Content, error) {
+			return pages[pageId], nil
+		},
+		getRootPages: func(expand []string) ([]*cf.Content, error) {
+			return []*cf.Content{pages["1"], pages["5"]}, nil
+		},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	var tree []string
+	var walk func(pages []*cf.Content, indent string)
+	walk = func(pages []*cf.Content, indent string) {
+		for _, page := range pages {
+			tree = append(tree, indent+page.Title)
+			children, err := selector.children(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			walk(children, indent+"  ")
+		}
+	}
+	rootPages, err := selector.rootPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk(rootPages, "")
+	return tree
+}
+
+func TestPageSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		options selectionOptions
+		want    []string
+	}{
+		{
+			name:    "everything",
+			options: selectionOptions{excludedParents: excludedParentsReparent},
+			want:    []string{"Home", "  Archive", "    Old plan", "  Team", "Drafts"},
+		},
+		{
+			name:    "root page",
+			options: selectionOptions{rootPage: "2", excludedParents: excludedParentsReparent},
+			want:    []string{"Archive", "  Old plan"},
+		},
+		{
+			name:    "excluded label reparents",
+			options: selectionOptions{excludeLabels: []string{"archived"}, excludedParents: excludedParentsReparent},
+			want:    []string{"Home", "  Old plan", "  Team", "Drafts"},
+		},
+		{
+			name:    "excluded label skips",
+			options: selectionOptions{excludeLabels: []string{"archived"}, excludedParents: excludedParentsSkip},
+			want:    []string{"Home", "  Team", "Drafts"},
+		},
+		{
+			name:    "included label",
+			options: selectionOptions{includeLabels: []string{"team", "other"}, excludedParents: excludedParentsReparent},
+			want:    []string{"Team"},
+		},
+		{
+			name:    "excluded title",
+			options: selectionOptions{excludeTitle: regexp.MustCompile(`^Drafts$`), excludedParents: excludedParentsSkip},
+			want:    []string{"Home", "  Archive", "    Old plan", "  Team"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectedTree(t, tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected tree = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageSelectorCQL(t *testing.T) {
+	pages := testSpace()
+	selector := &pageSelector{
+		options:    selectionOptions{excludedParents: excludedParentsReparent},
+		cqlPageIds: map[string]bool{"3": true, "5": true},
+		getPage: func(pageId string, expand []string) (*cf.Content, error) {
+			return pages[pageId], nil
+		},
+		getRootPages: func(expand []string) ([]*cf.Content, error) {
+			return []*cf.Content{pages["1"], pages["5"]}, nil
+		},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	rootPages, err := selector.rootPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, page := range rootPages {
+		got = append(got, page.ID)
+	}
+	if want := []string{"3", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("root pages = %v, want %v", got, want)
+	}
+}
+
+func TestPageSelectorPagesByStatus(t *testing.T) {
+	archived := []*cf.Content{
+		{ID: "7", Title: "Old notes", Ancestors: []*cf.Content{{ID: "1"}, {ID: "6"}}},
+		{ID: "6", Title: "Old team", Ancestors: []*cf.Content{{ID: "1"}}},
+		{ID: "8", Title: "Elsewhere", Ancestors: []*cf.Content{{ID: "5"}}},
+	}
+	selector := &pageSelector{
+		options: selectionOptions{rootPage: "1", excludedParents: excludedParentsReparent},
+		getPagesByStatus: func(status string, expand []string) ([]*cf.Content, error) {
+			if !slices.Contains(expand, "ancestors") || slices.Contains(expand, "children.page") {
+				t.Errorf("pages by status expanded with %v", expand)
+			}
+			return archived, nil
+		},
+		expand: []string{"version", "children.page"},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	pages, err := selector.pagesByStatus("archived")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, page := range pages {
+		got = append(got, page.ID)
+	}
+	if want := []string{"6", "7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages by status = %v, want parents first and only below the root page: %v", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/outline"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/google/uuid"
+)
+
+// templatePage returns the template as content, for the steps of the page
+// pipeline and the report, which work on pages.
+func templatePage(template *confluence.Template) *cf.Content {
+	return &cf.Content{
+		ID:    template.ID,
+		Type:  "template",
+		Title: template.Name,
+		Body:  &cf.Body{StorageView: &cf.View{Representation: "storage", Value: template.Body.Storage.Value}},
+	}
+}
+
+// migrateTemplates converts the page and blueprint templates of the space
+// with the page pipeline and creates them as templates of the collection.
+// It runs once every page has been mapped to its document, so that links to
+// pages point at Outline. Blueprint templates whose content an app generates
+// are listed in the report instead, as are the attachments of templates,
+// which Confluence does not let us download.
+func (m *Migrator) migrateTemplates() error {
+	templates, err := m.confluenceClient.GetTemplates(m.spaceKey)
+	if err != nil {
+		return fmt.Errorf("failed to get Confluence templates: %w", err)
+	}
+	for _, template := range templates {
+		page := templatePage(template)
+		if strings.TrimSpace(template.Body.Storage.Value) == "" {
+			m.logger.Info("Skipping template without content", "templateId", template.ID, "templateName", template.Name)
+			m.report.add(page, "blueprint template without content skipped, as its app generates the content")
+			continue
+		}
+		converter := transform.StorageConverter{
+			ConfluenceBaseURL: m.confluenceClient.GetBaseURL(),
+			SpaceKey:          m.spaceKey,
+			PageURL:           confluenceDisplayURL,
+			Template:          true,
+			Report: func(issue string) {
+				m.report.add(page, issue)
+			},
+		}
+		doc, err := converter.Convert(template.Name, template.Body.Storage.Value)
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s (%s): %w", template.ID, template.Name, err)
+		}
+		if err := append(m.pagePipeline(page), m.linksTransformer()).Run(doc); err != nil {
+			return fmt.Errorf("failed to transform template %s (%s): %w", template.ID, template.Name, err)
+		}
+		markdown, err := htmlToMarkdown(doc)
+		if err != nil {
+			return fmt.Errorf("failed to convert template %s (%s) to Markdown: %w", template.ID, template.Name, err)
+		}
+		if err := m.createTemplate(template, stripTitleHeading(markdown)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTemplate creates an Outline template of the collection named after
+// template with the Markdown text.
+func (m *Migrator) createTemplate(template *confluence.Template, text string) error {
+	isTemplate := true
+	created, err := m.outlineClient.CreateDocument(outline.PostDocumentsCreateJSONRequestBody{
+		CollectionId: uuid.MustParse(m.collectionId),
+		Title:        template.Name,
+		Text:         &text,
+		Template:     &isTemplate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create template %s (%s): %w", template.ID, template.Name, err)
+	}
+	if created.JSON200 == nil || created.JSON200.Data == nil {
+		return fmt.Errorf("creating template %s (%s) failed: status %d body: %s", template.ID, template.Name, created.StatusCode(), string(created.Body))
+	}
+	m.logger.Info("Created template", "documentId", created.JSON200.Data.Id.String(), "templateName", template.Name)
+	return nil
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/oskarspakers/confluence-to-outline/confluence"
+	"github.com/oskarspakers/confluence-to-outline/outline"
+	"github.com/oskarspakers/confluence-to-outline/transform"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+	"github.com/google/uuid"
+)
+
+type placeholderDocument struct {
+	page  *cf.Content
+	docId string
+}
+
+// migrateTwoPhase migrates the tree below rootPages and the blog posts
+// without a fixURLs pass.
+// The first phase creates an empty document for every page to learn its
+// Outline URL; the second exports each page, rewrites links to other pages in
+// the HTML and writes the converted Markdown into the placeholder, so links
+// are correct from the first write.
+// When the migration fails, the placeholders that were not written yet are
+// deleted rather than left empty in the collection.
+func (m *Migrator) migrateTwoPhase(rootPages []*cf.Content, posts []*confluence.BlogPost) (err error) {
+	var placeholders []placeholderDocument
+	filled := 0
+	defer func() {
+		if err != nil {
+			m.deletePlaceholders(placeholders[filled:])
+		}
+	}()
+	// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves Confluence order.
+	for i := len(rootPages) - 1; i >= 0; i-- {
+		if err := m.createPlaceholderRecurse(rootPages[i], m.parentDocumentId, &placeholders); err != nil {
+			return err
+		}
+	}
+	createPlaceholder := func(m *Migrator, page *cf.Content, parentDocumentId string) error {
+		return m.createPlaceholderRecurse(page, parentDocumentId, &placeholders)
+	}
+	if err := m.migrateBlogPosts(posts, createPlaceholder); err != nil {
+		return err
+	}
+	if err := m.migrateStatusPages(createPlaceholder); err != nil {
+		return err
+	}
+	if err := m.createLabelIndex(); err != nil {
+		return err
+	}
+	outputDataToJSON(m.urlMap, "urlMap")
+
+	var checkStringJSON []JsonOutputVars
+	for _, placeholder := range placeholders {
+		documentData, err := m.fillPlaceholder(placeholder)
+		if err != nil {
+			return err
+		}
+		filled++
+		if m.markRegex != "" {
+			checkStringJSON = m.markRegexFunc(documentData, checkStringJSON)
+		}
+	}
+	outputMarkedPages(checkStringJSON, "Marked")
+	return nil
+}
+
+// deletePlaceholders moves the placeholders to the trash, the pages below
+// others first. Pages are written parents first, so no written document is
+// below one of them.
+func (m *Migrator) deletePlaceholders(placeholders []placeholderDocument) {
+	for i := len(placeholders) - 1; i >= 0; i-- {
+		docId := placeholders[i].docId
+		resp, err := m.outlineClient.Client.PostDocumentsDeleteWithResponse(context.Background(), outline.PostDocumentsDeleteJSONRequestBody{
+			Id: docId,
+		})
+		if err != nil {
+			m.logger.Error("Failed to delete placeholder document", "documentId", docId, "error", err)
+			continue
+		}
+		if resp.StatusCode() != http.StatusOK {
+			m.logger.Error("Failed to delete placeholder document", "documentId", docId, "status", resp.StatusCode(), "body", string(resp.Body))
+			continue
+		}
+		m.logger.Info("Deleted placeholder document", "documentId", docId, "documentTitle", placeholders[i].page.Title)
+	}
+}
+
+func (m *Migrator) createPlaceholderRecurse(page *cf.Content, parentDocumentId string, placeholders *[]placeholderDocument) error {
+	restricted, err := m.viewRestricted(page)
+	if err != nil {
+		return err
+	}
+	if restricted {
+		restrictedMigrator, parentDocumentId := m.restrictedMigrator(page, parentDocumentId)
+		if restrictedMigrator == nil {
+			return nil
+		}
+		return restrictedMigrator.createPlaceholderRecurse(page, parentDocumentId, placeholders)
+	}
+
+	publish := !m.unpublished
+	createBody := outline.PostDocumentsCreateJSONRequestBody{
+		CollectionId: uuid.MustParse(m.collectionId),
+		Title:        page.Title,
+		Publish:      &publish,
+	}
+	if parentDocumentId != "" {
+		parentDocumentUuid := uuid.MustParse(parentDocumentId)
+		createBody.ParentDocumentId = &parentDocumentUuid
+	}
+	created, err := m.outlineClient.CreateDocument(createBody)
+	if err != nil {
+		return fmt.Errorf("failed to create placeholder for page %s (%s): %w", page.ID, page.Title, err)
+	}
+	if created.JSON200 == nil || created.JSON200.Data == nil {
+		return fmt.Errorf("placeholder creation failed for page %s (%s): status %d body: %s", page.ID, page.Title, created.StatusCode(), string(created.Body))
+	}
+	m.createPageMapping(page, created.JSON200.Data)
+
+	createdDocumentId := created.JSON200.Data.Id.String()
+	if m.unpublished {
+		m.unpublishedDocs[createdDocumentId] = true
+	}
+	*placeholders = append(*placeholders, placeholderDocument{page: page, docId: createdDocumentId})
+	m.logger.Info("Created placeholder document", "documentId", createdDocumentId, "documentTitle", page.Title)
+
+	childPages, err := m.selector.children(page)
+	if err != nil {
+		return err
+	}
+	// Iterate in reverse: Outline inserts new docs at the top of siblings, so reversing preserves Confluence order.
+	for i := len(childPages) - 1; i >= 0; i-- {
+		if err := m.createPlaceholderRecurse(childPages[i], createdDocumentId, placeholders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) fillPlaceholder(placeholder placeholderDocument) (DocumentData, error) {
+	page := placeholder.page
+	pipeline := append(m.pagePipeline(page), m.linksTransformer())
+	doc, err := exportAndTransform(m.confluenceClient, page, m.spaceKey, m.conversion, m.logger, pipeline)
+	if err != nil {
+		return DocumentData{}, err
+	}
+	markdown, err := htmlToMarkdown(doc)
+	if err != nil {
+		return DocumentData{}, fmt.Errorf("failed to convert page %s (%s) to Markdown: %w", page.ID, page.Title, err)
+	}
+
+	documentData := DocumentData{
+		DocId:   placeholder.docId,
+		DocBody: stripTitleHeading(markdown),
+		Title:   page.Title,
+	}
+	if err := m.updateOutlineDocument(documentData); err != nil {
+		return DocumentData{}, fmt.Errorf("failed to write content of page %s (%s): %w", page.ID, page.Title, err)
+	}
+	m.logger.Info("Wrote document content", "documentId", placeholder.docId, "documentTitle", page.Title)
+	return documentData, nil
+}
+
+// linksTransformer returns the step pointing links to migrated pages at
+// their documents, once the URL map holds every page.
+func (m *Migrator) linksTransformer() transform.Links {
+	return transform.Links{
+		ConfluenceHostname: strings.TrimSuffix(m.confluenceClient.GetBaseURL(), "/"),
+		NewHostname:        strings.TrimSuffix(m.outlineClient.GetBaseURL(), "/api"),
+		Lookup: func(confluencePath string) (string, bool) {
+			urlMapEntry, ok := m.urlMap[confluencePath]
+			return urlMapEntry.NewUrl, ok
+		},
+	}
+}
+
+// stripTitleHeading removes the title heading that ExportHTML puts at the top
+// of every page, since Outline stores the title separately.
+func stripTitleHeading(markdown string) string {
+	if first, rest, found := strings.Cut(markdown, "\n"); found && strings.HasPrefix(first, "# ") {
+		return strings.TrimLeft(rest, "\n")
+	}
+	return markdown
+}
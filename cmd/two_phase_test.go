@@ -0,0 +1,12 @@
+package cmd
+
+import "testing"
+
+func TestStripTitleHeading(t *testing.T) {
+	if got := stripTitleHeading("# Home\n\nBody\n"); got != "Body\n" {
+		t.Errorf("stripTitleHeading() = %q, want %q", got, "Body\n")
+	}
+	if got := stripTitleHeading("Body\n"); got != "Body\n" {
+		t.Errorf("stripTitleHeading() = %q, want %q", got, "Body\n")
+	}
+}
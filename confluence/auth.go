@@ -0,0 +1,194 @@
+package confluence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Auth modes selected with CONFLUENCE_AUTH.
+const (
+	AuthModeBasic  = "basic"
+	AuthModeBearer = "bearer"
+	AuthModeOAuth  = "oauth"
+	AuthModeCookie = "cookie"
+)
+
+// defaultOAuthTokenURL is the token endpoint of Atlassian's OAuth 2.0 (3LO)
+// apps.
+const defaultOAuthTokenURL = "https://auth.atlassian.com/oauth/token"
+
+// Auth adds credentials to requests to Confluence.
+type Auth interface {
+	Authorize(req *http.Request) error
+}
+
+// AuthEmpty sends requests without credentials.
+type AuthEmpty struct{}
+
+func (a AuthEmpty) Authorize(req *http.Request) error {
+	return nil
+}
+
+// BasicAuth authenticates with a username and an API token or password.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Authorize(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerAuth authenticates with a Server/Data Center personal access token.
+type BearerAuth struct {
+	Token string
+}
+
+func (a BearerAuth) Authorize(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// CookieAuth authenticates with the cookies of a browser session, for
+// instances behind single sign-on that allow neither basic auth nor tokens.
+type CookieAuth struct {
+	// Cookie is the value of the Cookie header, e.g.
+	// "JSESSIONID=...; seraph.confluence=...".
+	Cookie string
+}
+
+func (a CookieAuth) Authorize(req *http.Request) error {
+	req.Header.Set("Cookie", a.Cookie)
+	return nil
+}
+
+// OAuth authenticates with the access tokens of an OAuth 2.0 app, which it
+// obtains with a refresh token whenever the current one is about to expire.
+// Atlassian rotates refresh tokens on use; when TokenFile is set, the
+// current refresh token is written to it so that the next run can start
+// from it.
+type OAuth struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	TokenURL     string
+	TokenFile    string
+
+	accessToken string
+	expiry      time.Time
+}
+
+func (a *OAuth) Authorize(req *http.Request) error {
+	if a.accessToken == "" || time.Now().After(a.expiry.Add(-time.Minute)) {
+		if err := a.refresh(); err != nil {
+			return fmt.Errorf("failed to refresh Confluence OAuth token: %w", err)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.accessToken)
+	return nil
+}
+
+func (a *OAuth) refresh() error {
+	body, err := json.Marshal(map[string]string{
+		"grant_type":    "refresh_token",
+		"client_id":     a.ClientID,
+		"client_secret": a.ClientSecret,
+		"refresh_token": a.RefreshToken,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(a.TokenURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	a.accessToken = token.AccessToken
+	a.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if token.RefreshToken != "" && token.RefreshToken != a.RefreshToken {
+		a.RefreshToken = token.RefreshToken
+		if a.TokenFile != "" {
+			if err := os.WriteFile(a.TokenFile, []byte(a.RefreshToken), 0600); err != nil {
+				return fmt.Errorf("failed to save rotated refresh token: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// authFromEnv returns the Auth configured by CONFLUENCE_AUTH. Without it,
+// basic auth is used when CONFLUENCE_USERNAME and CONFLUENCE_API_TOKEN are
+// set and requests are anonymous otherwise.
+func authFromEnv() (Auth, error) {
+	username := os.Getenv("CONFLUENCE_USERNAME")
+	apiToken := os.Getenv("CONFLUENCE_API_TOKEN")
+	mode := os.Getenv("CONFLUENCE_AUTH")
+	if mode == "" {
+		if username == "" || apiToken == "" {
+			return AuthEmpty{}, nil
+		}
+		mode = AuthModeBasic
+	}
+
+	switch mode {
+	case AuthModeBasic:
+		if username == "" || apiToken == "" {
+			return nil, fmt.Errorf("CONFLUENCE_AUTH=basic needs CONFLUENCE_USERNAME and CONFLUENCE_API_TOKEN")
+		}
+		return BasicAuth{Username: username, Password: apiToken}, nil
+	case AuthModeBearer:
+		if apiToken == "" {
+			return nil, fmt.Errorf("CONFLUENCE_AUTH=bearer needs the personal access token in CONFLUENCE_API_TOKEN")
+		}
+		return BearerAuth{Token: apiToken}, nil
+	case AuthModeCookie:
+		cookie := os.Getenv("CONFLUENCE_COOKIE")
+		if cookie == "" {
+			return nil, fmt.Errorf("CONFLUENCE_AUTH=cookie needs CONFLUENCE_COOKIE")
+		}
+		return CookieAuth{Cookie: cookie}, nil
+	case AuthModeOAuth:
+		auth := &OAuth{
+			ClientID:     os.Getenv("CONFLUENCE_OAUTH_CLIENT_ID"),
+			ClientSecret: os.Getenv("CONFLUENCE_OAUTH_CLIENT_SECRET"),
+			RefreshToken: os.Getenv("CONFLUENCE_OAUTH_REFRESH_TOKEN"),
+			TokenURL:     os.Getenv("CONFLUENCE_OAUTH_TOKEN_URL"),
+			TokenFile:    os.Getenv("CONFLUENCE_OAUTH_TOKEN_FILE"),
+		}
+		if auth.TokenURL == "" {
+			auth.TokenURL = defaultOAuthTokenURL
+		}
+		// A refresh token saved by an earlier run replaces the configured
+		// one, which Atlassian has invalidated by rotating it.
+		if auth.TokenFile != "" {
+			if saved, err := os.ReadFile(auth.TokenFile); err == nil && len(bytes.TrimSpace(saved)) > 0 {
+				auth.RefreshToken = string(bytes.TrimSpace(saved))
+			}
+		}
+		if auth.ClientID == "" || auth.ClientSecret == "" || auth.RefreshToken == "" {
+			return nil, fmt.Errorf("CONFLUENCE_AUTH=oauth needs CONFLUENCE_OAUTH_CLIENT_ID, CONFLUENCE_OAUTH_CLIENT_SECRET and CONFLUENCE_OAUTH_REFRESH_TOKEN")
+		}
+		return auth, nil
+	}
+	return nil, fmt.Errorf("invalid CONFLUENCE_AUTH %q: must be %s", mode, strings.Join([]string{AuthModeBasic, AuthModeBearer, AuthModeOAuth, AuthModeCookie}, ", "))
+}
@@ -0,0 +1,173 @@
+package confluence
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTokenServer serves an OAuth token endpoint that accepts the refresh
+// token "refresh-<n>" and answers with access token "access-<n+1>" and, as
+// Atlassian does, the rotated refresh token "refresh-<n+1>".
+func newTokenServer(t *testing.T) (*httptest.Server, *[]map[string]string) {
+	t.Helper()
+	var requests []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, body)
+		var n int
+		if _, err := fmt.Sscanf(body["refresh_token"], "refresh-%d", &n); err != nil {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", n+1),
+			"refresh_token": fmt.Sprintf("refresh-%d", n+1),
+			"expires_in":    3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func authorization(t *testing.T, auth Auth) string {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	if err := auth.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func TestOAuthRefresh(t *testing.T) {
+	server, requests := newTokenServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "refresh-token")
+	auth := &OAuth{ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh-1", TokenURL: server.URL, TokenFile: tokenFile}
+
+	if got := authorization(t, auth); got != "Bearer access-2" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer access-2")
+	}
+	// The access token is reused until it is about to expire.
+	if got := authorization(t, auth); got != "Bearer access-2" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer access-2")
+	}
+	want := []map[string]string{{"grant_type": "refresh_token", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh-1"}}
+	if !reflect.DeepEqual(*requests, want) {
+		t.Errorf("token requests = %v, want %v", *requests, want)
+	}
+
+	// The rotated refresh token is used for the next refresh and saved.
+	if auth.RefreshToken != "refresh-2" {
+		t.Errorf("RefreshToken = %q, want %q", auth.RefreshToken, "refresh-2")
+	}
+	if saved, err := os.ReadFile(tokenFile); err != nil || string(saved) != "refresh-2" {
+		t.Errorf("token file = %q, %v, want %q", saved, err, "refresh-2")
+	}
+	auth.accessToken = ""
+	if got := authorization(t, auth); got != "Bearer access-3" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer access-3")
+	}
+	if last := (*requests)[len(*requests)-1]["refresh_token"]; last != "refresh-2" {
+		t.Errorf("refresh_token = %q, want %q", last, "refresh-2")
+	}
+	if saved, err := os.ReadFile(tokenFile); err != nil || string(saved) != "refresh-3" {
+		t.Errorf("token file = %q, %v, want %q", saved, err, "refresh-3")
+	}
+}
+
+func TestOAuthRefreshFailure(t *testing.T) {
+	server, _ := newTokenServer(t)
+	auth := &OAuth{ClientID: "id", ClientSecret: "secret", RefreshToken: "revoked", TokenURL: server.URL}
+	if err := auth.Authorize(httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Error("Authorize() succeeded with a rejected refresh token")
+	}
+}
+
+func TestAuthFromEnv(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "refresh-token")
+	if err := os.WriteFile(tokenFile, []byte("saved-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Auth
+		wantErr bool
+	}{
+		{
+			name: "anonymous without credentials",
+			want: AuthEmpty{},
+		},
+		{
+			name: "basic with username and token",
+			env:  map[string]string{"CONFLUENCE_USERNAME": "ann", "CONFLUENCE_API_TOKEN": "token"},
+			want: BasicAuth{Username: "ann", Password: "token"},
+		},
+		{
+			name:    "basic without token",
+			env:     map[string]string{"CONFLUENCE_AUTH": "basic", "CONFLUENCE_USERNAME": "ann"},
+			wantErr: true,
+		},
+		{
+			name: "bearer",
+			env:  map[string]string{"CONFLUENCE_AUTH": "bearer", "CONFLUENCE_API_TOKEN": "pat"},
+			want: BearerAuth{Token: "pat"},
+		},
+		{
+			name: "cookie",
+			env:  map[string]string{"CONFLUENCE_AUTH": "cookie", "CONFLUENCE_COOKIE": "JSESSIONID=1"},
+			want: CookieAuth{Cookie: "JSESSIONID=1"},
+		},
+		{
+			name: "oauth with the default token URL",
+			env: map[string]string{"CONFLUENCE_AUTH": "oauth", "CONFLUENCE_OAUTH_CLIENT_ID": "id",
+				"CONFLUENCE_OAUTH_CLIENT_SECRET": "secret", "CONFLUENCE_OAUTH_REFRESH_TOKEN": "refresh"},
+			want: &OAuth{ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh", TokenURL: defaultOAuthTokenURL},
+		},
+		{
+			name: "oauth prefers the saved refresh token",
+			env: map[string]string{"CONFLUENCE_AUTH": "oauth", "CONFLUENCE_OAUTH_CLIENT_ID": "id",
+				"CONFLUENCE_OAUTH_CLIENT_SECRET": "secret", "CONFLUENCE_OAUTH_REFRESH_TOKEN": "refresh",
+				"CONFLUENCE_OAUTH_TOKEN_URL": "https://auth.example.com/token", "CONFLUENCE_OAUTH_TOKEN_FILE": tokenFile},
+			want: &OAuth{ClientID: "id", ClientSecret: "secret", RefreshToken: "saved-token",
+				TokenURL: "https://auth.example.com/token", TokenFile: tokenFile},
+		},
+		{
+			name:    "oauth without client",
+			env:     map[string]string{"CONFLUENCE_AUTH": "oauth", "CONFLUENCE_OAUTH_REFRESH_TOKEN": "refresh"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			env:     map[string]string{"CONFLUENCE_AUTH": "kerberos"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CONFLUENCE_AUTH", "CONFLUENCE_USERNAME", "CONFLUENCE_API_TOKEN", "CONFLUENCE_COOKIE",
+				"CONFLUENCE_OAUTH_CLIENT_ID", "CONFLUENCE_OAUTH_CLIENT_SECRET", "CONFLUENCE_OAUTH_REFRESH_TOKEN",
+				"CONFLUENCE_OAUTH_TOKEN_URL", "CONFLUENCE_OAUTH_TOKEN_FILE"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := authFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("authFromEnv() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
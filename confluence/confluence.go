@@ -8,28 +8,25 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	cf "github.com/essentialkaos/go-confluence/v6"
 	"github.com/joho/godotenv"
 )
 
-type AuthEmpty struct {
-}
-
-func (a AuthEmpty) Validate() error {
-	return nil
-}
-
-func (a AuthEmpty) Encode() string {
-	return ""
-}
-
 type ConfluenceExtendedClient struct {
-	Client   *cf.API
-	baseUrl  string
-	username string
-	apiToken string
+	baseUrl string
+	// auth authenticates every request, so that cookies and refreshed OAuth
+	// tokens work too.
+	auth Auth
+	// v2 is set when the site is served by the REST API v2, which is used
+	// instead of the v1 endpoints.
+	v2 *v2Client
+	// statuses holds the status of the pages listed by GetPagesByStatus,
+	// whose bodies are only served when the status is asked for.
+	statuses map[string]string
 }
 
 func GetClient() (*ConfluenceExtendedClient, error) {
@@ -41,61 +38,333 @@ func GetClient() (*ConfluenceExtendedClient, error) {
 	if confluenceBaseUrl == "" {
 		return nil, fmt.Errorf("CONFLUENCE_BASE_URL is not set")
 	}
-	username := os.Getenv("CONFLUENCE_USERNAME")
-	apiToken := os.Getenv("CONFLUENCE_API_TOKEN")
-	var auth cf.Auth
-	if username != "" && apiToken != "" {
-		auth = cf.AuthBasic{User: username, Password: apiToken}
-	} else {
-		auth = AuthEmpty{}
-	}
-	api, err := cf.NewAPI(confluenceBaseUrl, auth)
-
+	auth, err := authFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	return &ConfluenceExtendedClient{
-		Client:   api,
+	return NewClient(confluenceBaseUrl, auth)
+}
+
+// NewClient returns a client for the Confluence site at baseUrl that
+// authenticates with auth.
+func NewClient(confluenceBaseUrl string, auth Auth) (*ConfluenceExtendedClient, error) {
+	client := &ConfluenceExtendedClient{
 		baseUrl:  confluenceBaseUrl,
-		username: username,
-		apiToken: apiToken,
-	}, nil
+		auth:     auth,
+		statuses: make(map[string]string),
+	}
+
+	// Cloud is deprecating the v1 content endpoints, so Cloud sites use v2
+	// unless CONFLUENCE_API_VERSION says otherwise.
+	apiVersion := os.Getenv("CONFLUENCE_API_VERSION")
+	switch {
+	case apiVersion == "v2", apiVersion == "" && isCloudURL(confluenceBaseUrl):
+		var err error
+		client.v2, err = newV2Client(confluenceBaseUrl, auth)
+		if err != nil {
+			return nil, err
+		}
+	case apiVersion != "" && apiVersion != "v1":
+		return nil, fmt.Errorf("invalid CONFLUENCE_API_VERSION %q: must be v1 or v2", apiVersion)
+	}
+	return client, nil
 }
 
 func (c *ConfluenceExtendedClient) GetBaseURL() string {
 	return c.baseUrl
 }
 
-type confluencePageResponse struct {
-	Body struct {
-		ExportView struct {
+// GetSpace returns the space with the given key.
+func (c *ConfluenceExtendedClient) GetSpace(spaceKey string) (*cf.Space, error) {
+	if c.v2 != nil {
+		return c.v2.GetSpace(spaceKey)
+	}
+	var space cf.Space
+	if err := getJSON(c.auth, c.apiURL("/space/"+url.PathEscape(spaceKey), nil), &space); err != nil {
+		return nil, err
+	}
+	return &space, nil
+}
+
+// GetHomePageID returns the id of the home page of the space with the given
+// key, or "" when it has none.
+func (c *ConfluenceExtendedClient) GetHomePageID(spaceKey string) (string, error) {
+	if c.v2 != nil {
+		return c.v2.GetHomePageID(spaceKey)
+	}
+	var space struct {
+		Homepage *struct {
+			ID string `json:"id"`
+		} `json:"homepage"`
+	}
+	if err := getJSON(c.auth, c.apiURL("/space/"+url.PathEscape(spaceKey), url.Values{"expand": {"homepage"}}), &space); err != nil {
+		return "", err
+	}
+	if space.Homepage == nil {
+		return "", nil
+	}
+	return space.Homepage.ID, nil
+}
+
+// GetRootPages returns the top-level pages of the space with the given key,
+// with the v1 expansions in expand.
+func (c *ConfluenceExtendedClient) GetRootPages(spaceKey string, expand []string) ([]*cf.Content, error) {
+	if c.v2 != nil {
+		return c.v2.GetRootPages(spaceKey, expand)
+	}
+	return v1GetAll[*cf.Content](c, c.apiURL("/space/"+url.PathEscape(spaceKey)+"/content/page", url.Values{
+		"depth":  {"root"},
+		"expand": {strings.Join(expand, ",")},
+	}))
+}
+
+// BlogPost is a blog post with the date it was published, which is not part
+// of cf.Content.
+type BlogPost struct {
+	*cf.Content
+	Published time.Time
+	// Author is the display name of the author, or "" where the API only
+	// gives an account id, as the v2 API does.
+	Author string
+}
+
+// GetBlogPosts returns the current blog posts of the space with the given
+// key, with the v1 expansions in expand.
+func (c *ConfluenceExtendedClient) GetBlogPosts(spaceKey string, expand []string) ([]*BlogPost, error) {
+	if c.v2 != nil {
+		return c.v2.GetBlogPosts(spaceKey, expand)
+	}
+	type v1BlogPost struct {
+		cf.Content
+		History *cf.History `json:"history"`
+	}
+	results, err := v1GetAll[*v1BlogPost](c, c.apiURL("/space/"+url.PathEscape(spaceKey)+"/content/blogpost", url.Values{
+		"expand": {strings.Join(append(slices.Clone(expand), "history"), ",")},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	var posts []*BlogPost
+	for _, result := range results {
+		post := &BlogPost{Content: &result.Content}
+		if result.History != nil && result.History.CreatedDate != nil {
+			post.Published = result.History.CreatedDate.Time
+		}
+		if result.History != nil && result.History.CreatedBy != nil {
+			post.Author = result.History.CreatedBy.DisplayName
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// Page statuses other than current that GetPagesByStatus lists.
+const (
+	StatusArchived = "archived"
+	StatusDraft    = "draft"
+)
+
+// GetPagesByStatus returns the pages of the space with the given key and
+// status, with the v1 expansions in expand. Unlike current pages, they are
+// listed flat rather than walked from the root pages.
+func (c *ConfluenceExtendedClient) GetPagesByStatus(spaceKey, status string, expand []string) ([]*cf.Content, error) {
+	var pages []*cf.Content
+	var err error
+	if c.v2 != nil {
+		pages, err = c.v2.GetPagesByStatus(spaceKey, status, expand)
+	} else {
+		pages, err = v1GetAll[*cf.Content](c, c.apiURL("/content", url.Values{
+			"spaceKey": {spaceKey},
+			"type":     {"page"},
+			"status":   {status},
+			"expand":   {strings.Join(expand, ",")},
+		}))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		c.statuses[page.ID] = status
+	}
+	return pages, nil
+}
+
+// ReadRestricted reports whether viewing the page with the given id is
+// restricted to some users or groups. Pages below a restricted page inherit
+// its restriction without having one of their own.
+func (c *ConfluenceExtendedClient) ReadRestricted(pageId string) (bool, error) {
+	var resp struct {
+		Restrictions struct {
+			User struct {
+				Size int `json:"size"`
+			} `json:"user"`
+			Group struct {
+				Size int `json:"size"`
+			} `json:"group"`
+		} `json:"restrictions"`
+	}
+	restrictionURL := c.apiURL("/content/"+pageId+"/restriction/byOperation/read", url.Values{
+		"expand": {"restrictions.user,restrictions.group"},
+	})
+	if err := getJSON(c.auth, restrictionURL, &resp); err != nil {
+		return false, err
+	}
+	return resp.Restrictions.User.Size > 0 || resp.Restrictions.Group.Size > 0, nil
+}
+
+// SearchPages returns the pages matched by the CQL query. CQL search has no
+// v2 equivalent, so it uses the v1 API on Cloud sites too.
+func (c *ConfluenceExtendedClient) SearchPages(cql string) ([]*cf.Content, error) {
+	return v1GetAll[*cf.Content](c, c.apiURL("/content/search", url.Values{
+		"cql":   {cql},
+		"limit": {"100"},
+	}))
+}
+
+// Template is a content template of a space: a page template created by its
+// users, or a blueprint template provided by an app.
+type Template struct {
+	ID          string `json:"templateId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// TemplateType is "page" or "blueprint".
+	TemplateType string `json:"templateType"`
+	Body         struct {
+		Storage struct {
 			Value string `json:"value"`
-		} `json:"export_view"`
+		} `json:"storage"`
 	} `json:"body"`
-	Title string `json:"title"`
 }
 
-func (c *ConfluenceExtendedClient) ExportDoc(pageId string) (*string, error) {
-	filename := pageId + ".html"
+// GetTemplates returns the page and blueprint templates of the space with
+// the given key, with their bodies in storage format. Blueprint templates
+// whose content an app generates have an empty body. The v1 endpoints are
+// used on every site, as the v2 API has none for templates.
+func (c *ConfluenceExtendedClient) GetTemplates(spaceKey string) ([]*Template, error) {
+	var templates []*Template
+	for _, templateType := range []string{"page", "blueprint"} {
+		results, err := v1GetAll[*Template](c, c.apiURL("/template/"+templateType, url.Values{
+			"spaceKey": {spaceKey},
+			"expand":   {"body"},
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s templates: %w", templateType, err)
+		}
+		templates = append(templates, results...)
+	}
+	return templates, nil
+}
 
-	url := c.baseUrl + "/rest/api/content/" + pageId + "?expand=body.export_view"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+// v1GetAll follows the next links of a paginated v1 list endpoint, starting
+// at apiURL, and returns the results of all pages.
+func v1GetAll[T any](c *ConfluenceExtendedClient, apiURL string) ([]T, error) {
+	var all []T
+	next := apiURL
+	for next != "" {
+		var resp struct {
+			Results []T `json:"results"`
+			Links   struct {
+				Next string `json:"next"`
+			} `json:"_links"`
+		}
+		if err := getJSON(c.auth, next, &resp); err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Results...)
+		next = ""
+		if resp.Links.Next != "" {
+			next = strings.TrimSuffix(c.baseUrl, "/") + resp.Links.Next
+		}
+	}
+	return all, nil
+}
+
+// GetPage returns the page with the given id, with the v1 expansions in
+// expand.
+func (c *ConfluenceExtendedClient) GetPage(pageId string, expand []string) (*cf.Content, error) {
+	if c.v2 != nil {
+		return c.v2.GetPage(pageId, expand)
+	}
+	var page cf.Content
+	if err := getJSON(c.auth, c.apiURL("/content/"+pageId, url.Values{"expand": {strings.Join(expand, ",")}}), &page); err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.username, c.apiToken)
+	return &page, nil
+}
+
+// apiURL returns the URL of the v1 REST API path with the given query.
+func (c *ConfluenceExtendedClient) apiURL(path string, query url.Values) string {
+	apiURL := strings.TrimSuffix(c.baseUrl, "/") + "/rest/api" + path
+	if len(query) > 0 {
+		apiURL += "?" + query.Encode()
+	}
+	return apiURL
+}
+
+// getJSON decodes the JSON response to a GET request of apiURL,
+// authenticated with auth, into out.
+func getJSON(auth Auth, apiURL string, out any) error {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return err
+	}
+	if err := auth.Authorize(req); err != nil {
+		return err
+	}
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s: status %d: %s", apiURL, resp.StatusCode, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type confluencePageResponse struct {
+	Body struct {
+		ExportView struct {
+			Value string `json:"value"`
+		} `json:"export_view"`
+		AtlasDocFormat struct {
+			Value string `json:"value"`
+		} `json:"atlas_doc_format"`
+	} `json:"body"`
+	Title string `json:"title"`
+}
+
+// getPageBody fetches a page with the given body representation expanded.
+func (c *ConfluenceExtendedClient) getPageBody(pageId, representation string) (*confluencePageResponse, error) {
+	query := url.Values{"expand": {"body." + representation}}
+	if status, ok := c.statuses[pageId]; ok {
+		query.Set("status", status)
+	}
+	pageURL := c.apiURL("/content/"+pageId, query)
+	if c.v2 != nil {
+		pageURL = c.v2.wikiURL + "/api/v2" + c.v2.contentPath(pageId) + "?body-format=" + representation
+		if c.statuses[pageId] == StatusDraft {
+			pageURL += "&get-draft=true"
+		}
+	}
 	var pageResp confluencePageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&pageResp); err != nil {
+	if err := getJSON(c.auth, pageURL, &pageResp); err != nil {
 		return nil, err
 	}
+	return &pageResp, nil
+}
+
+// ExportHTML fetches the export view of a page and wraps it in a complete
+// HTML document whose <title> and leading <h1> carry the page title.
+func (c *ConfluenceExtendedClient) ExportHTML(pageId string) (string, error) {
+	pageResp, err := c.getPageBody(pageId, "export_view")
+	if err != nil {
+		return "", err
+	}
 
 	escapedTitle := html.EscapeString(pageResp.Title)
 	htmlContent := fmt.Sprintf("<html><head><meta charset=\"utf-8\"><title>%s</title></head><body><h1>%s</h1>%s</body></html>",
@@ -103,25 +372,52 @@ func (c *ConfluenceExtendedClient) ExportDoc(pageId string) (*string, error) {
 		escapedTitle,
 		pageResp.Body.ExportView.Value,
 	)
-	if err := os.MkdirAll("export", 0755); err != nil {
-		return nil, err
+	return htmlContent, nil
+}
+
+// AtlasDocFormat fetches the body of a page in the Atlassian Document
+// Format, as JSON. Pages that were never edited in Cloud's editor are
+// converted by Confluence.
+func (c *ConfluenceExtendedClient) AtlasDocFormat(pageId string) (string, error) {
+	pageResp, err := c.getPageBody(pageId, "atlas_doc_format")
+	if err != nil {
+		return "", err
 	}
-	if err := os.WriteFile("export/"+filename, []byte(htmlContent), 0644); err != nil {
-		return nil, err
+	if pageResp.Body.AtlasDocFormat.Value == "" {
+		return "", fmt.Errorf("page %s has no atlas_doc_format body", pageId)
+	}
+	return pageResp.Body.AtlasDocFormat.Value, nil
+}
+
+// DownloadAttachment downloads the attachment of a page with the given
+// filename.
+func (c *ConfluenceExtendedClient) DownloadAttachment(pageId, filename string) ([]byte, string, error) {
+	if c.v2 != nil {
+		downloadURL, err := c.v2.attachmentDownloadURL(pageId, filename)
+		if err != nil {
+			return nil, "", err
+		}
+		return c.DownloadImage(downloadURL)
 	}
+	return c.DownloadImage(strings.TrimSuffix(c.baseUrl, "/") + "/download/attachments/" + pageId + "/" + url.PathEscape(filename))
+}
 
-	return &filename, nil
+// sameOrigin reports whether u has the scheme and host of baseURL, so that
+// credentials are not sent to hosts that merely contain the Confluence host
+// name.
+func sameOrigin(u *url.URL, baseURL string) bool {
+	base, err := url.Parse(baseURL)
+	return err == nil && base.Host != "" && strings.EqualFold(u.Scheme, base.Scheme) && strings.EqualFold(u.Host, base.Host)
 }
 
 func (c *ConfluenceExtendedClient) DownloadImage(imageUrl string) ([]byte, string, error) {
-	// Use a client that follows redirects but only sends Basic Auth to the Confluence host.
+	// Use a client that follows redirects but only sends credentials to the Confluence host.
 	// Confluence attachment URLs redirect to api.media.atlassian.com (JWT in URL, no auth needed).
-	parsedBase, _ := url.Parse(c.baseUrl)
-	confluenceHost := parsedBase.Host
 	httpClient := &http.Client{
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if req.URL.Host != confluenceHost {
+			if !sameOrigin(req.URL, c.baseUrl) {
 				req.Header.Del("Authorization")
+				req.Header.Del("Cookie")
 			}
 			return nil
 		},
@@ -131,9 +427,11 @@ func (c *ConfluenceExtendedClient) DownloadImage(imageUrl string) ([]byte, strin
 	if err != nil {
 		return nil, "", err
 	}
-	// Only set basic auth for Confluence URLs; media.atlassian.com uses JWT in URL
-	if strings.Contains(imageUrl, confluenceHost) {
-		req.SetBasicAuth(c.username, c.apiToken)
+	// Only authenticate Confluence URLs; media.atlassian.com uses JWT in URL
+	if sameOrigin(req.URL, c.baseUrl) {
+		if err := c.auth.Authorize(req); err != nil {
+			return nil, "", err
+		}
 	}
 
 	resp, err := httpClient.Do(req)
@@ -0,0 +1,29 @@
+package confluence
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.atlassian.net/wiki/download/attachments/1/a.png", true},
+		{"https://Example.atlassian.net/wiki/download/attachments/1/a.png", true},
+		{"https://example.atlassian.net.evil.io/wiki/download/attachments/1/a.png", false},
+		{"https://api.media.atlassian.com/file/1/binary?token=jwt", false},
+		{"http://example.atlassian.net/wiki/download/attachments/1/a.png", false},
+		{"https://evil.io/example.atlassian.net/a.png", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sameOrigin(u, "https://example.atlassian.net/wiki"); got != tt.want {
+			t.Errorf("sameOrigin(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package confluence
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+// v2PageLimit is the page size requested from list endpoints, the maximum
+// most of them allow.
+const v2PageLimit = "250"
+
+// v2Client calls the Confluence Cloud REST API v2, which replaces the v1
+// content endpoints on Cloud. List endpoints are paginated with a cursor:
+// every response links to the next page of results until there are none.
+type v2Client struct {
+	// wikiURL is the URL the API paths are relative to: the base URL up to
+	// /wiki, which the next links of paginated responses start with.
+	wikiURL string
+	auth    Auth
+	// blogPostIds holds the blog posts listed by GetBlogPosts, whose bodies
+	// and attachments are served at the blog post endpoints rather than the
+	// page ones.
+	blogPostIds map[string]bool
+}
+
+type v2Links struct {
+	Next string `json:"next"`
+}
+
+type v2Space struct {
+	ID         string `json:"id"`
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	HomepageID string `json:"homepageId"`
+}
+
+type v2Page struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Title    string `json:"title"`
+	ParentID string `json:"parentId"`
+	// CreatedAt is when a blog post was published.
+	CreatedAt time.Time `json:"createdAt"`
+	Version   *struct {
+		Number    int       `json:"number"`
+		CreatedAt time.Time `json:"createdAt"`
+	} `json:"version"`
+	Body struct {
+		Storage *cf.View `json:"storage"`
+	} `json:"body"`
+}
+
+type v2Label struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+}
+
+type v2Attachment struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	MediaType    string `json:"mediaType"`
+	DownloadLink string `json:"downloadLink"`
+}
+
+// isCloudURL reports whether baseUrl is a Confluence Cloud site, which is
+// served from *.atlassian.net, or from api.atlassian.com for OAuth apps.
+func isCloudURL(baseUrl string) bool {
+	u, err := url.Parse(baseUrl)
+	return err == nil && (strings.HasSuffix(u.Hostname(), ".atlassian.net") || u.Hostname() == "api.atlassian.com")
+}
+
+func newV2Client(baseUrl string, auth Auth) (*v2Client, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONFLUENCE_BASE_URL: %w", err)
+	}
+	wikiURL := strings.TrimSuffix(baseUrl, "/")
+	if !strings.HasSuffix(wikiURL, "/wiki") {
+		wikiURL = u.Scheme + "://" + u.Host + "/wiki"
+	}
+	return &v2Client{wikiURL: wikiURL, auth: auth, blogPostIds: make(map[string]bool)}, nil
+}
+
+// get decodes the JSON response to the API path, which starts with /wiki,
+// into out.
+func (c *v2Client) get(path string, out any) error {
+	return getJSON(c.auth, c.wikiURL+strings.TrimPrefix(path, "/wiki"), out)
+}
+
+// v2GetAll follows the cursor of a paginated list endpoint and returns the
+// results of all pages.
+func v2GetAll[T any](c *v2Client, path string, query url.Values) ([]T, error) {
+	query.Set("limit", v2PageLimit)
+	next := "/wiki/api/v2" + path + "?" + query.Encode()
+	var all []T
+	for next != "" {
+		var resp struct {
+			Results []T     `json:"results"`
+			Links   v2Links `json:"_links"`
+		}
+		if err := c.get(next, &resp); err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Results...)
+		next = resp.Links.Next
+	}
+	return all, nil
+}
+
+func (c *v2Client) getSpace(spaceKey string) (*v2Space, error) {
+	spaces, err := v2GetAll[v2Space](c, "/spaces", url.Values{"keys": {spaceKey}})
+	if err != nil {
+		return nil, err
+	}
+	if len(spaces) == 0 {
+		return nil, fmt.Errorf("space %s not found", spaceKey)
+	}
+	return &spaces[0], nil
+}
+
+// GetSpace returns the space in the shape of the v1 API.
+func (c *v2Client) GetSpace(spaceKey string) (*cf.Space, error) {
+	space, err := c.getSpace(spaceKey)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := strconv.Atoi(space.ID)
+	return &cf.Space{ID: id, Key: space.Key, Name: space.Name, Type: space.Type}, nil
+}
+
+// GetHomePageID returns the id of the home page of the space, or "" when it
+// has none.
+func (c *v2Client) GetHomePageID(spaceKey string) (string, error) {
+	space, err := c.getSpace(spaceKey)
+	if err != nil {
+		return "", err
+	}
+	return space.HomepageID, nil
+}
+
+// GetRootPages returns the top-level pages of the space. expand takes the
+// v1 expansions the callers use: body.storage, children.page, ancestors and
+// metadata.labels.
+func (c *v2Client) GetRootPages(spaceKey string, expand []string) ([]*cf.Content, error) {
+	space, err := c.getSpace(spaceKey)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"depth": {"root"}, "status": {"current"}}
+	if slices.Contains(expand, "body.storage") {
+		query.Set("body-format", "storage")
+	}
+	pages, err := v2GetAll[v2Page](c, "/spaces/"+space.ID+"/pages", query)
+	if err != nil {
+		return nil, err
+	}
+	var contents []*cf.Content
+	for _, page := range pages {
+		content, err := c.expand(page, "page", expand)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, nil
+}
+
+// GetPagesByStatus returns the pages of the space with the given status,
+// expanded like GetRootPages.
+func (c *v2Client) GetPagesByStatus(spaceKey, status string, expand []string) ([]*cf.Content, error) {
+	space, err := c.getSpace(spaceKey)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"status": {status}}
+	if slices.Contains(expand, "body.storage") {
+		query.Set("body-format", "storage")
+	}
+	pages, err := v2GetAll[v2Page](c, "/spaces/"+space.ID+"/pages", query)
+	if err != nil {
+		return nil, err
+	}
+	var contents []*cf.Content
+	for _, page := range pages {
+		content, err := c.expand(page, "page", expand)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, nil
+}
+
+// GetPage returns the page with the given id, expanded like GetRootPages.
+func (c *v2Client) GetPage(pageId string, expand []string) (*cf.Content, error) {
+	path := "/wiki/api/v2" + c.contentPath(pageId)
+	if slices.Contains(expand, "body.storage") {
+		path += "?body-format=storage"
+	}
+	var page v2Page
+	if err := c.get(path, &page); err != nil {
+		return nil, err
+	}
+	contentType := "page"
+	if c.blogPostIds[pageId] {
+		contentType = "blogpost"
+	}
+	return c.expand(page, contentType, expand)
+}
+
+// GetBlogPosts returns the current blog posts of the space, expanded like
+// GetRootPages.
+func (c *v2Client) GetBlogPosts(spaceKey string, expand []string) ([]*BlogPost, error) {
+	space, err := c.getSpace(spaceKey)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"status": {"current"}}
+	if slices.Contains(expand, "body.storage") {
+		query.Set("body-format", "storage")
+	}
+	blogPosts, err := v2GetAll[v2Page](c, "/spaces/"+space.ID+"/blogposts", query)
+	if err != nil {
+		return nil, err
+	}
+	var posts []*BlogPost
+	for _, blogPost := range blogPosts {
+		c.blogPostIds[blogPost.ID] = true
+		content, err := c.expand(blogPost, "blogpost", expand)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, &BlogPost{Content: content, Published: blogPost.CreatedAt})
+	}
+	return posts, nil
+}
+
+// contentPath returns the API path of the page or blog post with the given
+// id.
+func (c *v2Client) contentPath(pageId string) string {
+	if c.blogPostIds[pageId] {
+		return "/blogposts/" + pageId
+	}
+	return "/pages/" + pageId
+}
+
+// expand converts page, of the given v1 content type, to the shape of the
+// v1 API and fetches the child pages and labels that v2 lists at their own
+// endpoints.
+func (c *v2Client) expand(page v2Page, contentType string, expand []string) (*cf.Content, error) {
+	content := &cf.Content{
+		ID:     page.ID,
+		Type:   contentType,
+		Status: page.Status,
+		Title:  page.Title,
+	}
+	if page.Version != nil {
+		content.Version = &cf.Version{Number: page.Version.Number, When: &cf.Date{Time: page.Version.CreatedAt}}
+	}
+	if page.Body.Storage != nil {
+		content.Body = &cf.Body{StorageView: page.Body.Storage}
+	}
+	if contentType == "page" && slices.Contains(expand, "children.page") {
+		children, err := v2GetAll[v2Page](c, "/pages/"+page.ID+"/children", url.Values{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list children of page %s: %w", page.ID, err)
+		}
+		collection := &cf.ContentCollection{Size: len(children)}
+		for _, child := range children {
+			collection.Results = append(collection.Results, &cf.Content{ID: child.ID, Type: "page", Status: child.Status, Title: child.Title})
+		}
+		content.Children = &cf.Contents{Pages: collection}
+	}
+	if slices.Contains(expand, "ancestors") && page.ParentID != "" {
+		var ancestors struct {
+			Results []struct {
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			} `json:"results"`
+		}
+		if err := c.get("/wiki/api/v2"+c.contentPath(page.ID)+"/ancestors", &ancestors); err != nil {
+			return nil, fmt.Errorf("failed to list ancestors of page %s: %w", page.ID, err)
+		}
+		for _, ancestor := range ancestors.Results {
+			content.Ancestors = append(content.Ancestors, &cf.Content{ID: ancestor.ID, Type: ancestor.Type})
+		}
+	}
+	if slices.Contains(expand, "metadata.labels") {
+		labels, err := v2GetAll[v2Label](c, c.contentPath(page.ID)+"/labels", url.Values{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list labels of page %s: %w", page.ID, err)
+		}
+		collection := &cf.LabelCollection{Size: len(labels)}
+		for _, label := range labels {
+			collection.Result = append(collection.Result, &cf.Label{ID: label.ID, Name: label.Name, Prefix: label.Prefix})
+		}
+		content.Metadata = &cf.Metadata{Labels: collection}
+	}
+	return content, nil
+}
+
+// attachmentDownloadURL returns the absolute download URL of the attachment
+// of the page with the given filename.
+func (c *v2Client) attachmentDownloadURL(pageId, filename string) (string, error) {
+	attachments, err := v2GetAll[v2Attachment](c, c.contentPath(pageId)+"/attachments", url.Values{"filename": {filename}})
+	if err != nil {
+		return "", err
+	}
+	for _, attachment := range attachments {
+		if attachment.Title == filename {
+			return c.wikiURL + attachment.DownloadLink, nil
+		}
+	}
+	return "", fmt.Errorf("attachment %s not found on page %s", filename, pageId)
+}
@@ -0,0 +1,161 @@
+package confluence
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	cf "github.com/essentialkaos/go-confluence/v6"
+)
+
+// newV2TestServer serves responses, keyed by request path and query, and
+// records the requests it gets.
+func newV2TestServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if r.URL.RawQuery != "" {
+			key += "?" + r.URL.RawQuery
+		}
+		requests = append(requests, key)
+		body, ok := responses[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestNewV2ClientWikiURL(t *testing.T) {
+	tests := []struct {
+		baseUrl string
+		want    string
+	}{
+		{"https://example.atlassian.net/wiki", "https://example.atlassian.net/wiki"},
+		{"https://example.atlassian.net/wiki/", "https://example.atlassian.net/wiki"},
+		{"https://example.atlassian.net", "https://example.atlassian.net/wiki"},
+		{"https://example.atlassian.net/", "https://example.atlassian.net/wiki"},
+		{"https://api.atlassian.com/ex/confluence/cloud-id/wiki", "https://api.atlassian.com/ex/confluence/cloud-id/wiki"},
+	}
+	for _, tt := range tests {
+		c, err := newV2Client(tt.baseUrl, AuthEmpty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.wikiURL != tt.want {
+			t.Errorf("newV2Client(%q).wikiURL = %q, want %q", tt.baseUrl, c.wikiURL, tt.want)
+		}
+	}
+}
+
+func TestV2GetAllFollowsNextLinks(t *testing.T) {
+	server, requests := newV2TestServer(t, map[string]string{
+		"/wiki/api/v2/spaces/1/pages?limit=250&status=current": `{"results":[{"id":"1"},{"id":"2"}],"_links":{"next":"/wiki/api/v2/spaces/1/pages?cursor=b&limit=250"}}`,
+		"/wiki/api/v2/spaces/1/pages?cursor=b&limit=250":       `{"results":[{"id":"3"}],"_links":{"next":"/wiki/api/v2/spaces/1/pages?cursor=c&limit=250"}}`,
+		"/wiki/api/v2/spaces/1/pages?cursor=c&limit=250":       `{"results":[{"id":"4"}],"_links":{}}`,
+	})
+	// The base URL lacks /wiki, which the next links start with.
+	c, err := newV2Client(server.URL, AuthEmpty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := v2GetAll[v2Page](c, "/spaces/1/pages", url.Values{"status": {"current"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, page := range pages {
+		ids = append(ids, page.ID)
+	}
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("v2GetAll() ids = %v, want %v", ids, want)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("requests = %v, want 3", *requests)
+	}
+}
+
+func TestV2GetRootPagesExpand(t *testing.T) {
+	server, _ := newV2TestServer(t, map[string]string{
+		"/wiki/api/v2/spaces?keys=ENG&limit=250": `{"results":[{"id":"7","key":"ENG","name":"Engineering","homepageId":"10"}]}`,
+		"/wiki/api/v2/spaces/7/pages?body-format=storage&depth=root&limit=250&status=current": `{"results":[` +
+			`{"id":"10","status":"current","title":"Home","parentId":"9","version":{"number":3,"createdAt":"2026-01-02T03:04:05Z"},` +
+			`"body":{"storage":{"value":"<p>Hi</p>","representation":"storage"}}}]}`,
+		"/wiki/api/v2/pages/10/children?limit=250": `{"results":[{"id":"11","status":"current","title":"Child"}]}`,
+		"/wiki/api/v2/pages/10/ancestors":          `{"results":[{"id":"9","type":"page"}]}`,
+		"/wiki/api/v2/pages/10/labels?limit=250":   `{"results":[{"id":"5","name":"howto","prefix":"global"}]}`,
+	})
+	c, err := newV2Client(server.URL+"/wiki", AuthEmpty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := c.GetRootPages("ENG", []string{"body.storage", "children.page", "ancestors", "metadata.labels"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("GetRootPages() = %d pages, want 1", len(pages))
+	}
+	page := pages[0]
+	if page.ID != "10" || page.Type != "page" || page.Status != "current" || page.Title != "Home" {
+		t.Errorf("page = %+v", page)
+	}
+	if page.Version == nil || page.Version.Number != 3 || page.Version.When.Year() != 2026 {
+		t.Errorf("page.Version = %+v", page.Version)
+	}
+	if page.Body == nil || page.Body.StorageView == nil || page.Body.StorageView.Value != "<p>Hi</p>" {
+		t.Errorf("page.Body = %+v", page.Body)
+	}
+	wantChildren := &cf.ContentCollection{Size: 1, Results: []*cf.Content{{ID: "11", Type: "page", Status: "current", Title: "Child"}}}
+	if page.Children == nil || !reflect.DeepEqual(page.Children.Pages, wantChildren) {
+		t.Errorf("page.Children = %+v, want %+v", page.Children, wantChildren)
+	}
+	wantAncestors := []*cf.Content{{ID: "9", Type: "page"}}
+	if !reflect.DeepEqual(page.Ancestors, wantAncestors) {
+		t.Errorf("page.Ancestors = %+v, want %+v", page.Ancestors, wantAncestors)
+	}
+	wantLabels := &cf.LabelCollection{Size: 1, Result: []*cf.Label{{ID: "5", Name: "howto", Prefix: "global"}}}
+	if page.Metadata == nil || !reflect.DeepEqual(page.Metadata.Labels, wantLabels) {
+		t.Errorf("page.Metadata = %+v, want %+v", page.Metadata, wantLabels)
+	}
+}
+
+func TestV2AttachmentDownloadURL(t *testing.T) {
+	server, _ := newV2TestServer(t, map[string]string{
+		"/wiki/api/v2/pages/10/attachments?filename=a+b.png&limit=250": `{"results":[` +
+			`{"id":"att1","title":"a b.png.old","downloadLink":"/download/attachments/10/a%20b.png.old"},` +
+			`{"id":"att2","title":"a b.png","downloadLink":"/download/attachments/10/a%20b.png?version=2&api=v2"}]}`,
+		"/wiki/api/v2/blogposts/20/attachments?filename=c.png&limit=250": `{"results":[{"id":"att3","title":"c.png","downloadLink":"/download/attachments/20/c.png"}]}`,
+	})
+	c, err := newV2Client(server.URL+"/wiki/", AuthEmpty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.blogPostIds["20"] = true
+
+	tests := []struct {
+		pageId   string
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{pageId: "10", filename: "a b.png", want: server.URL + "/wiki/download/attachments/10/a%20b.png?version=2&api=v2"},
+		{pageId: "20", filename: "c.png", want: server.URL + "/wiki/download/attachments/20/c.png"},
+		{pageId: "10", filename: "missing.png", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := c.attachmentDownloadURL(tt.pageId, tt.filename)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("attachmentDownloadURL(%q, %q) = %q, %v, want %q", tt.pageId, tt.filename, got, err, tt.want)
+		}
+	}
+}
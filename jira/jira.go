@@ -0,0 +1,206 @@
+// Package jira is a minimal Jira REST client for looking up the issues that
+// Confluence pages embed.
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// issueFields are the fields fetched for every issue.
+const issueFields = "summary,status,issuetype,priority,assignee,resolution,created,updated"
+
+type Client struct {
+	baseUrl  string
+	username string
+	apiToken string
+	// cloud is set for Jira Cloud sites, which have removed the v2 search
+	// endpoint that Server and Data Center still serve.
+	cloud      bool
+	httpClient *http.Client
+}
+
+type Issue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Status  *struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		IssueType *struct {
+			Name string `json:"name"`
+		} `json:"issuetype"`
+		Priority *struct {
+			Name string `json:"name"`
+		} `json:"priority"`
+		Assignee *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Resolution *struct {
+			Name string `json:"name"`
+		} `json:"resolution"`
+		Created string `json:"created"`
+		Updated string `json:"updated"`
+	} `json:"fields"`
+}
+
+type searchResponse struct {
+	Issues []Issue `json:"issues"`
+	Total  int     `json:"total"`
+}
+
+// cloudSearchResponse is a page of the Cloud /rest/api/3/search/jql
+// endpoint, which pages with a token and does not count the matches.
+type cloudSearchResponse struct {
+	Issues        []Issue `json:"issues"`
+	NextPageToken string  `json:"nextPageToken"`
+	IsLast        bool    `json:"isLast"`
+}
+
+// GetClient creates a client from JIRA_BASE_URL, JIRA_USERNAME and
+// JIRA_API_TOKEN. The credentials default to the Confluence ones, which is
+// what Atlassian Cloud sites sharing one account need.
+func GetClient() (*Client, error) {
+	// Without a .env file the variables are read from the environment.
+	_ = godotenv.Load()
+	jiraBaseUrl := os.Getenv("JIRA_BASE_URL")
+	if jiraBaseUrl == "" {
+		return nil, fmt.Errorf("JIRA_BASE_URL is not set")
+	}
+	username := os.Getenv("JIRA_USERNAME")
+	if username == "" {
+		username = os.Getenv("CONFLUENCE_USERNAME")
+	}
+	apiToken := os.Getenv("JIRA_API_TOKEN")
+	if apiToken == "" {
+		apiToken = os.Getenv("CONFLUENCE_API_TOKEN")
+	}
+	return NewClient(jiraBaseUrl, username, apiToken), nil
+}
+
+func NewClient(baseUrl, username, apiToken string) *Client {
+	return &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		username:   username,
+		apiToken:   apiToken,
+		cloud:      isCloudURL(baseUrl),
+		httpClient: http.DefaultClient,
+	}
+}
+
+func isCloudURL(baseUrl string) bool {
+	u, err := url.Parse(baseUrl)
+	return err == nil && (strings.HasSuffix(u.Hostname(), ".atlassian.net") || u.Hostname() == "api.atlassian.com")
+}
+
+func (c *Client) GetBaseURL() string {
+	return c.baseUrl
+}
+
+// GetIssue fetches a single issue by key.
+func (c *Client) GetIssue(key string) (*Issue, error) {
+	var issue Issue
+	query := url.Values{"fields": {issueFields}}
+	if err := c.get("/rest/api/2/issue/"+url.PathEscape(key)+"?"+query.Encode(), &issue); err != nil {
+		return nil, fmt.Errorf("failed to get Jira issue %s: %w", key, err)
+	}
+	return &issue, nil
+}
+
+// Search returns up to maxResults issues matching jql and the total number
+// of matching issues.
+func (c *Client) Search(jql string, maxResults int) ([]Issue, int, error) {
+	if c.cloud {
+		return c.searchCloud(jql, maxResults)
+	}
+	var result searchResponse
+	query := url.Values{
+		"jql":        {jql},
+		"maxResults": {strconv.Itoa(maxResults)},
+		"fields":     {issueFields},
+	}
+	if err := c.get("/rest/api/2/search?"+query.Encode(), &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to search Jira issues %q: %w", jql, err)
+	}
+	return result.Issues, result.Total, nil
+}
+
+// searchCloud searches with the Cloud v3 endpoint, following nextPageToken
+// until maxResults issues are fetched, and counts the matches separately.
+func (c *Client) searchCloud(jql string, maxResults int) ([]Issue, int, error) {
+	var issues []Issue
+	nextPageToken := ""
+	for len(issues) < maxResults {
+		query := url.Values{
+			"jql":        {jql},
+			"maxResults": {strconv.Itoa(maxResults - len(issues))},
+			"fields":     {issueFields},
+		}
+		if nextPageToken != "" {
+			query.Set("nextPageToken", nextPageToken)
+		}
+		var page cloudSearchResponse
+		if err := c.get("/rest/api/3/search/jql?"+query.Encode(), &page); err != nil {
+			return nil, 0, fmt.Errorf("failed to search Jira issues %q: %w", jql, err)
+		}
+		issues = append(issues, page.Issues...)
+		if page.IsLast || page.NextPageToken == "" || len(page.Issues) == 0 {
+			break
+		}
+		nextPageToken = page.NextPageToken
+	}
+	if len(issues) > maxResults {
+		issues = issues[:maxResults]
+	}
+
+	var count struct {
+		Count int `json:"count"`
+	}
+	body, err := json.Marshal(map[string]string{"jql": jql})
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := c.do("POST", "/rest/api/3/search/approximate-count", bytes.NewReader(body), &count); err != nil {
+		return nil, 0, fmt.Errorf("failed to count Jira issues %q: %w", jql, err)
+	}
+	// The count is approximate and may lag behind the search.
+	return issues, max(count.Count, len(issues)), nil
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	return c.do("GET", path, nil, v)
+}
+
+func (c *Client) do(method, path string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, c.baseUrl+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" && c.apiToken != "" {
+		req.SetBasicAuth(c.username, c.apiToken)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
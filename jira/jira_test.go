@@ -0,0 +1,115 @@
+package jira
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("maxResults")+" "+r.URL.Query().Get("nextPageToken"))
+		var body any
+		switch r.URL.Path {
+		case "/rest/api/2/search":
+			body = map[string]any{"issues": []map[string]any{{"key": "ENG-1"}, {"key": "ENG-2"}}, "total": 7}
+		case "/rest/api/3/search/jql":
+			if r.URL.Query().Get("nextPageToken") == "" {
+				body = map[string]any{"issues": []map[string]any{{"key": "ENG-1"}, {"key": "ENG-2"}}, "nextPageToken": "p2"}
+			} else {
+				body = map[string]any{"issues": []map[string]any{{"key": "ENG-3"}}, "isLast": true}
+			}
+		case "/rest/api/3/search/approximate-count":
+			body = map[string]any{"count": 3}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		cloud        bool
+		maxResults   int
+		wantKeys     []string
+		wantTotal    int
+		wantRequests []string
+	}{
+		{
+			name:         "server uses v2",
+			maxResults:   20,
+			wantKeys:     []string{"ENG-1", "ENG-2"},
+			wantTotal:    7,
+			wantRequests: []string{"GET /rest/api/2/search 20 "},
+		},
+		{
+			name:       "cloud follows nextPageToken",
+			cloud:      true,
+			maxResults: 20,
+			wantKeys:   []string{"ENG-1", "ENG-2", "ENG-3"},
+			wantTotal:  3,
+			wantRequests: []string{
+				"GET /rest/api/3/search/jql 20 ",
+				"GET /rest/api/3/search/jql 18 p2",
+				"POST /rest/api/3/search/approximate-count  ",
+			},
+		},
+		{
+			name:       "cloud stops at maxResults",
+			cloud:      true,
+			maxResults: 2,
+			wantKeys:   []string{"ENG-1", "ENG-2"},
+			wantTotal:  3,
+			wantRequests: []string{
+				"GET /rest/api/3/search/jql 2 ",
+				"POST /rest/api/3/search/approximate-count  ",
+			},
+		},
+		{
+			name:         "cloud count only",
+			cloud:        true,
+			wantTotal:    3,
+			wantRequests: []string{"POST /rest/api/3/search/approximate-count  "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			client := NewClient(server.URL, "user", "token")
+			client.cloud = tt.cloud
+			issues, total, err := client.Search("project = ENG", tt.maxResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, issue := range issues {
+				keys = append(keys, issue.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) || total != tt.wantTotal {
+				t.Errorf("Search() = %v, %d, want %v, %d", keys, total, tt.wantKeys, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestIsCloudURL(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://example.atlassian.net":                  true,
+		"https://api.atlassian.com/ex/jira/cloud-id":     true,
+		"https://jira.example.com":                       false,
+		"https://jira.example.com/atlassian.net/browse/": false,
+	} {
+		if got := isCloudURL(url); got != want {
+			t.Errorf("isCloudURL(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
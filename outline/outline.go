@@ -10,7 +10,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -56,19 +58,31 @@ type RateLimit struct {
 }
 
 func GetClient(logger *slog.Logger, rateLimit RateLimit) (*OutlineExtendedClient, error) {
+	return GetClientFromEnv(logger, rateLimit, "OUTLINE_BASE_URL", "OUTLINE_API_TOKEN")
+}
+
+// GetClientFromEnv creates a client for the Outline instance whose base URL
+// and API token are stored in the given environment variables. It lets a
+// command talk to more than one Outline instance.
+func GetClientFromEnv(logger *slog.Logger, rateLimit RateLimit, baseUrlVar string, apiTokenVar string) (*OutlineExtendedClient, error) {
 	err := godotenv.Load()
 	if err != nil {
-		logger.Info(".env file not loaded, reading OUTLINE_API_TOKEN and OUTLINE_BASE_URL from env variables.")
+		logger.Info(".env file not loaded, reading Outline settings from env variables.", "baseUrlVar", baseUrlVar, "apiTokenVar", apiTokenVar)
 	}
-	apiToken := os.Getenv("OUTLINE_API_TOKEN")
+	apiToken := os.Getenv(apiTokenVar)
 	if apiToken == "" {
-		return nil, fmt.Errorf("OUTLINE_API_TOKEN is not set")
+		return nil, fmt.Errorf("%s is not set", apiTokenVar)
 	}
-	outlineBaseUrl := os.Getenv("OUTLINE_BASE_URL")
+	outlineBaseUrl := os.Getenv(baseUrlVar)
 	if outlineBaseUrl == "" {
-		return nil, fmt.Errorf("OUTLINE_BASE_URL is not set")
+		return nil, fmt.Errorf("%s is not set", baseUrlVar)
 	}
+	return NewExtendedClient(logger, rateLimit, outlineBaseUrl, apiToken)
+}
 
+// NewExtendedClient creates a client for the Outline API at outlineBaseUrl
+// (ending in /api) authenticated with apiToken.
+func NewExtendedClient(logger *slog.Logger, rateLimit RateLimit, outlineBaseUrl string, apiToken string) (*OutlineExtendedClient, error) {
 	var doer HttpRequestDoer = &http.Client{}
 	if rateLimit.Requests > 0 && rateLimit.Window > 0 {
 		// Burst = 1 enforces strict pacing (one request per 1/rate seconds).
@@ -215,6 +229,64 @@ func (c *OutlineExtendedClient) UploadAttachment(imageData []byte, filename stri
 	return attachmentURL, nil
 }
 
+// sameOrigin reports whether u has the scheme and host of baseURL. Hosts are
+// compared exactly, as a prefix would also match look-alikes such as
+// outline.example.com.evil.io.
+func sameOrigin(u *url.URL, baseURL string) bool {
+	base, err := url.Parse(baseURL)
+	return err == nil && base.Host != "" && strings.EqualFold(u.Scheme, base.Scheme) && strings.EqualFold(u.Host, base.Host)
+}
+
+// DownloadAttachment fetches an attachment stored in this Outline instance.
+// attachmentURL may be relative to the instance (/api/attachments.redirect?id=...)
+// or absolute. Outline answers with a redirect to the storage backend; the
+// Authorization header is only sent to Outline itself.
+func (c *OutlineExtendedClient) DownloadAttachment(attachmentURL string) ([]byte, string, error) {
+	outlineHost := strings.TrimSuffix(c.outlineBaseUrl, "/api")
+	outlineHost = strings.TrimSuffix(outlineHost, "/")
+	if strings.HasPrefix(attachmentURL, "/") {
+		attachmentURL = outlineHost + attachmentURL
+	}
+
+	req, err := http.NewRequest(http.MethodGet, attachmentURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	doer := HttpRequestDoer(http.DefaultClient)
+	if sameOrigin(req.URL, outlineHost) {
+		if err := c.Client.ClientInterface.(*Client).applyEditors(context.Background(), req, nil); err != nil {
+			return nil, "", err
+		}
+		doer = c.httpDoer
+	}
+
+	// net/http drops the Authorization header when a redirect leaves the
+	// Outline host, so signed storage URLs are fetched without it.
+	resp, err := doer.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("failed to download attachment %s: status %d", attachmentURL, resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
 func (c *OutlineExtendedClient) CleanCollection(collection string) error {
 	var collectionId = uuid.MustParse(collection)
 	c.logger.Info("Cleaning collection", "Collection", collectionId)
@@ -274,9 +346,13 @@ func (c *OutlineExtendedClient) CleanCollection(collection string) error {
 
 }
 
+// CreateDocument creates a document, published unless body.Publish is
+// false.
 func (c *OutlineExtendedClient) CreateDocument(body PostDocumentsCreateJSONRequestBody) (*PostDocumentsCreateResponse, error) {
-	var publish = true
-	body.Publish = &publish
+	if body.Publish == nil {
+		var publish = true
+		body.Publish = &publish
+	}
 	return c.Client.PostDocumentsCreateWithResponse(context.Background(), body)
 }
 
@@ -324,7 +400,7 @@ func (c *OutlineExtendedClient) ImportDocument(body PostDocumentsImportMultipart
 		c.logger.Error("Error", "error", err)
 		return nil, err
 	}
-	_, err = publishField.Write([]byte("true"))
+	_, err = publishField.Write([]byte(strconv.FormatBool(body.Publish == nil || *body.Publish)))
 	if err != nil {
 		c.logger.Error("Error writing JSON data", "error", err)
 		return nil, err
@@ -0,0 +1,29 @@
+package outline
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://outline.example.com/api/attachments.redirect?id=1", true},
+		{"https://Outline.example.com/api/attachments.redirect?id=1", true},
+		{"https://outline.example.com.evil.io/api/attachments.redirect?id=1", false},
+		{"https://outline.example.com:8443/api/attachments.redirect?id=1", false},
+		{"http://outline.example.com/api/attachments.redirect?id=1", false},
+		{"https://evil.io/outline.example.com/api/attachments.redirect?id=1", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sameOrigin(u, "https://outline.example.com"); got != tt.want {
+			t.Errorf("sameOrigin(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
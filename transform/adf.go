@@ -0,0 +1,282 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// adfNode is a node of an Atlassian Document Format document.
+type adfNode struct {
+	Type    string         `json:"type"`
+	Attrs   map[string]any `json:"attrs"`
+	Content []adfNode      `json:"content"`
+	Text    string         `json:"text"`
+	Marks   []adfNode      `json:"marks"`
+}
+
+// attr returns the attribute key of n as a string, or "" if it is not set.
+func (n adfNode) attr(key string) string {
+	switch v := n.Attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// adfStatusColourClasses maps the colours of ADF status nodes to the lozenge
+// class of the export view.
+var adfStatusColourClasses = map[string]string{
+	"green":  "aui-lozenge-success",
+	"red":    "aui-lozenge-error",
+	"yellow": "aui-lozenge-current",
+	"blue":   "aui-lozenge-complete",
+	"purple": "aui-lozenge-progress",
+}
+
+// ADFConverter converts page bodies in the Atlassian Document Format that
+// Confluence Cloud's editor stores pages in. Like StorageConverter, the
+// result is HTML shaped like the export view, so the same transformers
+// apply to it and turn it into Outline's Markdown. Panels, expands,
+// mentions, dates and status lozenges keep their meaning, which the export
+// view flattens. Unknown node types are logged and replaced by their
+// content, or their text when they have none.
+type ADFConverter struct {
+	// ConfluenceBaseURL is the Confluence base URL, possibly including a
+	// context path such as /wiki. Attachments are linked below it.
+	ConfluenceBaseURL string
+	PageID            string
+	Logger            *slog.Logger
+}
+
+// Convert returns the HTML document of the page titled title with the given
+// ADF body, which is JSON. Like the export, the title is both the <title>
+// and a leading <h1>.
+func (c ADFConverter) Convert(title, adf string) (*html.Node, error) {
+	var root adfNode
+	if err := json.Unmarshal([]byte(adf), &root); err != nil {
+		return nil, fmt.Errorf("failed to decode ADF document: %w", err)
+	}
+	escapedTitle := html.EscapeString(title)
+	doc, err := Parse(fmt.Sprintf("<html><head><meta charset=\"utf-8\"><title>%s</title></head><body><h1>%s</h1></body></html>",
+		escapedTitle,
+		escapedTitle,
+	))
+	if err != nil {
+		return nil, err
+	}
+	AppendChildren(Body(doc), c.nodes(root.Content)...)
+	return doc, nil
+}
+
+func (c ADFConverter) nodes(content []adfNode) []*html.Node {
+	var nodes []*html.Node
+	for _, n := range content {
+		nodes = append(nodes, c.node(n)...)
+	}
+	return nodes
+}
+
+// element builds an element with the given tag and attributes holding the
+// converted content of n.
+func (c ADFConverter) element(n adfNode, tag string, attrs ...string) *html.Node {
+	return AppendChildren(Element(tag, attrs...), c.nodes(n.Content)...)
+}
+
+func (c ADFConverter) node(n adfNode) []*html.Node {
+	switch n.Type {
+	case "text":
+		return []*html.Node{c.text(n)}
+	case "hardBreak":
+		return []*html.Node{Element("br")}
+	case "paragraph":
+		return []*html.Node{c.element(n, "p")}
+	case "heading":
+		level, err := strconv.Atoi(n.attr("level"))
+		if err != nil || level < 1 || level > 6 {
+			level = 1
+		}
+		return []*html.Node{c.element(n, "h"+strconv.Itoa(level))}
+	case "bulletList":
+		return []*html.Node{c.element(n, "ul")}
+	case "orderedList":
+		if order := n.attr("order"); order != "" && order != "1" {
+			return []*html.Node{c.element(n, "ol", "start", order)}
+		}
+		return []*html.Node{c.element(n, "ol")}
+	case "listItem":
+		return []*html.Node{c.element(n, "li")}
+	case "blockquote":
+		return []*html.Node{c.element(n, "blockquote")}
+	case "rule":
+		return []*html.Node{Element("hr")}
+	case "codeBlock":
+		code := Element("code")
+		if language := n.attr("language"); language != "" {
+			code = Element("code", "class", "language-"+language)
+		}
+		code.AppendChild(Text(adfText(n)))
+		return []*html.Node{AppendChildren(Element("pre"), code)}
+	case "panel":
+		panel := Element("div", "class", "panel")
+		if panelType := n.attr("panelType"); panelType != "" {
+			SetAttr(panel, "data-panel-type", panelType)
+		}
+		return []*html.Node{AppendChildren(panel, c.element(n, "div", "class", "panelContent"))}
+	case "expand", "nestedExpand":
+		return []*html.Node{AppendChildren(Element("div", "class", "expand-container", "data-macro-name", "expand"),
+			AppendChildren(Element("div", "class", "expand-control"),
+				AppendChildren(Element("span", "class", "expand-control-text"), Text(n.attr("title")))),
+			c.element(n, "div", "class", "expand-content"))}
+	case "table":
+		return []*html.Node{AppendChildren(Element("table"), c.element(n, "tbody"))}
+	case "tableRow":
+		return []*html.Node{c.element(n, "tr")}
+	case "tableHeader", "tableCell":
+		tag := "td"
+		if n.Type == "tableHeader" {
+			tag = "th"
+		}
+		cell := c.element(n, tag)
+		for _, span := range []string{"colspan", "rowspan"} {
+			if value := n.attr(span); value != "" && value != "1" {
+				SetAttr(cell, span, value)
+			}
+		}
+		return []*html.Node{cell}
+	case "taskList":
+		return []*html.Node{c.element(n, "ul", "class", "inline-task-list")}
+	case "taskItem":
+		if n.attr("state") == "DONE" {
+			return []*html.Node{c.element(n, "li", "class", "checked")}
+		}
+		return []*html.Node{c.element(n, "li")}
+	case "decisionList":
+		return []*html.Node{c.element(n, "ul")}
+	case "decisionItem":
+		return []*html.Node{AppendChildren(Element("li"), append([]*html.Node{Text("✔️ ")}, c.nodes(n.Content)...)...)}
+	case "layoutSection":
+		return []*html.Node{c.element(n, "div", "class", "columnLayout")}
+	case "layoutColumn":
+		return []*html.Node{AppendChildren(Element("div", "class", "cell"), c.element(n, "div", "class", "innerCell"))}
+	case "mediaSingle", "mediaGroup":
+		return []*html.Node{c.element(n, "p")}
+	case "media", "mediaInline":
+		return c.media(n)
+	case "mention":
+		text := strings.TrimPrefix(n.attr("text"), "@")
+		return []*html.Node{AppendChildren(Element("a", "class", "confluence-userlink user-mention"), Text("@"+text))}
+	case "emoji":
+		if text := n.attr("text"); text != "" {
+			return []*html.Node{Text(text)}
+		}
+		return []*html.Node{Text(n.attr("shortName"))}
+	case "date":
+		return []*html.Node{AppendChildren(Element("time", "datetime", adfDate(n.attr("timestamp"))), Text(adfDate(n.attr("timestamp"))))}
+	case "status":
+		class := "status-macro aui-lozenge"
+		if colourClass, ok := adfStatusColourClasses[n.attr("color")]; ok {
+			class += " " + colourClass
+		}
+		return []*html.Node{AppendChildren(Element("span", "class", class, "data-macro-name", "status"), Text(n.attr("text")))}
+	case "inlineCard":
+		url := n.attr("url")
+		return []*html.Node{AppendChildren(Element("a", "href", url), Text(url))}
+	case "blockCard", "embedCard":
+		url := n.attr("url")
+		return []*html.Node{AppendChildren(Element("p"), AppendChildren(Element("a", "href", url), Text(url)))}
+	case "extension", "bodiedExtension", "inlineExtension":
+		tag := "div"
+		if n.Type == "inlineExtension" {
+			tag = "span"
+		}
+		return []*html.Node{c.element(n, tag, "class", "conf-macro", "data-macro-name", n.attr("extensionKey"))}
+	case "placeholder":
+		return nil
+	}
+
+	c.Logger.Warn("Unknown ADF node type, keeping its content", "pageId", c.PageID, "type", n.Type)
+	if len(n.Content) > 0 {
+		return c.nodes(n.Content)
+	}
+	if text := firstNonEmpty(n.Text, n.attr("text")); text != "" {
+		return []*html.Node{Text(text)}
+	}
+	return nil
+}
+
+// text builds a text node wrapped in the elements of its marks.
+func (c ADFConverter) text(n adfNode) *html.Node {
+	node := Text(n.Text)
+	for _, mark := range n.Marks {
+		switch mark.Type {
+		case "strong":
+			node = AppendChildren(Element("strong"), node)
+		case "em":
+			node = AppendChildren(Element("em"), node)
+		case "code":
+			node = AppendChildren(Element("code"), node)
+		case "strike":
+			node = AppendChildren(Element("s"), node)
+		case "underline":
+			node = AppendChildren(Element("u"), node)
+		case "subsup":
+			if mark.attr("type") == "sup" {
+				node = AppendChildren(Element("sup"), node)
+			} else {
+				node = AppendChildren(Element("sub"), node)
+			}
+		case "link":
+			node = AppendChildren(Element("a", "href", mark.attr("href")), node)
+		}
+	}
+	return node
+}
+
+// media builds the <img> for a media node. Confluence attachments are
+// linked below the Confluence base URL by file name, so that Images
+// downloads them; media without a file name is dropped.
+func (c ADFConverter) media(n adfNode) []*html.Node {
+	src := n.attr("url")
+	if n.attr("type") != "external" {
+		filename := firstNonEmpty(n.attr("__fileName"), n.attr("alt"))
+		if filename == "" {
+			c.Logger.Warn("ADF media without a file name dropped", "pageId", c.PageID, "mediaId", n.attr("id"))
+			return nil
+		}
+		src = attachmentURL(c.ConfluenceBaseURL, c.PageID, filename)
+	}
+	img := Element("img", "src", src)
+	if alt := n.attr("alt"); alt != "" {
+		SetAttr(img, "alt", alt)
+	}
+	return []*html.Node{img}
+}
+
+// adfText returns the text of the text nodes below n.
+func adfText(n adfNode) string {
+	var b strings.Builder
+	b.WriteString(n.Text)
+	for _, child := range n.Content {
+		b.WriteString(adfText(child))
+	}
+	return b.String()
+}
+
+// adfDate formats the millisecond timestamp of a date node as a date.
+func adfDate(timestamp string) string {
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return timestamp
+	}
+	return time.UnixMilli(ms).UTC().Format(time.DateOnly)
+}
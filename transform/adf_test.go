@@ -0,0 +1,90 @@
+package transform
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestADFConverter(t *testing.T) {
+	converter := ADFConverter{
+		ConfluenceBaseURL: "https://example.atlassian.net/wiki",
+		PageID:            "42",
+		Logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "marks",
+			content: `{"type":"paragraph","content":[{"type":"text","text":"bold link","marks":[{"type":"strong"},{"type":"link","attrs":{"href":"https://example.com"}}]},{"type":"hardBreak"},{"type":"text","text":"x<y","marks":[{"type":"code"}]}]}`,
+			want:    `<p><a href="https://example.com"><strong>bold link</strong></a><br/><code>x&lt;y</code></p>`,
+		},
+		{
+			name:    "heading and code block",
+			content: `{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Setup"}]},{"type":"codeBlock","attrs":{"language":"go"},"content":[{"type":"text","text":"func main() {}"}]}`,
+			want:    `<h2>Setup</h2><pre><code class="language-go">func main() {}</code></pre>`,
+		},
+		{
+			name:    "panel",
+			content: `{"type":"panel","attrs":{"panelType":"warning"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Careful"}]}]}`,
+			want:    `<div class="panel" data-panel-type="warning"><div class="panelContent"><p>Careful</p></div></div>`,
+		},
+		{
+			name:    "expand",
+			content: `{"type":"expand","attrs":{"title":"Details"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Hidden"}]}]}`,
+			want:    `<div class="expand-container" data-macro-name="expand"><div class="expand-control"><span class="expand-control-text">Details</span></div><div class="expand-content"><p>Hidden</p></div></div>`,
+		},
+		{
+			name:    "mention, date, emoji and status",
+			content: `{"type":"paragraph","content":[{"type":"mention","attrs":{"id":"abc","text":"@Ann Lee"}},{"type":"text","text":" "},{"type":"date","attrs":{"timestamp":"1718236800000"}},{"type":"text","text":" "},{"type":"emoji","attrs":{"shortName":":smile:","text":"😄"}},{"type":"status","attrs":{"text":"DONE","color":"green"}}]}`,
+			want:    `<p><a class="confluence-userlink user-mention">@Ann Lee</a> <time datetime="2024-06-13">2024-06-13</time> 😄<span class="status-macro aui-lozenge aui-lozenge-success" data-macro-name="status">DONE</span></p>`,
+		},
+		{
+			name:    "task list",
+			content: `{"type":"taskList","content":[{"type":"taskItem","attrs":{"state":"DONE"},"content":[{"type":"text","text":"Ship"}]},{"type":"taskItem","attrs":{"state":"TODO"},"content":[{"type":"text","text":"Announce"}]}]}`,
+			want:    `<ul class="inline-task-list"><li class="checked">Ship</li><li>Announce</li></ul>`,
+		},
+		{
+			name:    "table with merged cell",
+			content: `{"type":"table","content":[{"type":"tableRow","content":[{"type":"tableHeader","attrs":{"colspan":2},"content":[{"type":"paragraph","content":[{"type":"text","text":"Owners"}]}]}]}]}`,
+			want:    `<table><tbody><tr><th colspan="2"><p>Owners</p></th></tr></tbody></table>`,
+		},
+		{
+			name:    "media",
+			content: `{"type":"mediaSingle","content":[{"type":"media","attrs":{"id":"f1","type":"file","collection":"contentId-42","alt":"chart.png"}}]}`,
+			want:    `<p><img src="https://example.atlassian.net/wiki/download/attachments/42/chart.png" alt="chart.png"/></p>`,
+		},
+		{
+			name:    "extension",
+			content: `{"type":"extension","attrs":{"extensionType":"com.atlassian.confluence.macro.core","extensionKey":"toc"}}`,
+			want:    `<div class="conf-macro" data-macro-name="toc"></div>`,
+		},
+		{
+			name:    "unknown node keeps its content",
+			content: `{"type":"futureBlock","content":[{"type":"paragraph","content":[{"type":"text","text":"Kept"}]}]},{"type":"futureInline","attrs":{"text":"fallback"}}`,
+			want:    `<p>Kept</p>fallback`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := converter.Convert("Title", `{"version":1,"type":"doc","content":[`+tt.content+`]}`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "<h1>Title</h1>" + tt.want
+			if got := renderBody(t, doc); got != want {
+				t.Errorf("Convert() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestADFConverterInvalidJSON(t *testing.T) {
+	if _, err := (ADFConverter{}).Convert("Title", "{"); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Attachments downloads the Confluence attachments that <a href> links to,
+// such as PDFs and office files, hands the bytes to Store and points the
+// link at the location Store returns. Links to anything else, and
+// attachments that fail to download or store, are left alone.
+type Attachments struct {
+	// ConfluenceBaseURL is the Confluence base URL, possibly including a
+	// context path such as /wiki.
+	ConfluenceBaseURL string
+	Download          func(attachmentUrl string) ([]byte, string, error)
+	Store             func(data []byte, filename string, contentType string) (string, error)
+	Logger            *slog.Logger
+}
+
+func (t Attachments) Name() string {
+	return "attachments"
+}
+
+func (t Attachments) Transform(doc *html.Node) error {
+	confluenceBase := strings.TrimSuffix(t.ConfluenceBaseURL, "/")
+	confluenceOrigin := confluenceBase
+	if u, err := url.Parse(confluenceBase); err == nil {
+		confluenceOrigin = u.Scheme + "://" + u.Host
+	}
+
+	// The same attachment is often linked several times; fetch it once.
+	stored := make(map[string]string)
+
+	for _, a := range FindAll(doc, ByTag("a")) {
+		href := Attr(a, "href")
+		attachmentPath := strings.SplitN(href, "?", 2)[0]
+		if !strings.Contains(attachmentPath, "/download/attachments/") {
+			continue
+		}
+		attachmentURL := resolveConfluenceImageURL(href, confluenceBase, confluenceOrigin)
+		if attachmentURL == "" {
+			continue
+		}
+
+		storedURL, ok := stored[attachmentURL]
+		if !ok {
+			filename := path.Base(attachmentPath)
+			if unescaped, err := url.PathUnescape(filename); err == nil {
+				filename = unescaped
+			}
+			data, contentType, err := t.Download(attachmentURL)
+			if err != nil {
+				t.Logger.Warn("Failed to download attachment", "url", attachmentURL, "error", err)
+				continue
+			}
+			storedURL, err = t.Store(data, filename, contentType)
+			if err != nil {
+				t.Logger.Warn("Failed to store attachment", "url", attachmentURL, "error", err)
+				continue
+			}
+			stored[attachmentURL] = storedURL
+		}
+		SetAttr(a, "href", storedURL)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	attachments := Attachments{
+		ConfluenceBaseURL: "https://example.atlassian.net/wiki",
+		Download: func(attachmentUrl string) ([]byte, string, error) {
+			if attachmentUrl == "https://example.atlassian.net/wiki/download/attachments/1/broken.pdf" {
+				return nil, "", errors.New("404")
+			}
+			return []byte("pdf"), "application/pdf", nil
+		},
+		Store: func(data []byte, filename string, contentType string) (string, error) {
+			return "attachments/" + filename, nil
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "relative link is downloaded",
+			body: `<a href="/wiki/download/attachments/1/Quarterly%20report.pdf?version=1&amp;api=v2">report</a>`,
+			want: `<a href="attachments/Quarterly report.pdf">report</a>`,
+		},
+		{
+			name: "absolute link is downloaded",
+			body: `<a href="https://example.atlassian.net/wiki/download/attachments/1/plan.xlsx">plan</a>`,
+			want: `<a href="attachments/plan.xlsx">plan</a>`,
+		},
+		{
+			name: "page links and external attachments are kept",
+			body: `<a href="/wiki/spaces/KEY/pages/1">page</a><a href="https://other.example.com/download/attachments/1/a.pdf">other</a>`,
+			want: `<a href="/wiki/spaces/KEY/pages/1">page</a><a href="https://other.example.com/download/attachments/1/a.pdf">other</a>`,
+		},
+		{
+			name: "failed download keeps the link",
+			body: `<a href="/wiki/download/attachments/1/broken.pdf">broken</a>`,
+			want: `<a href="/wiki/download/attachments/1/broken.pdf">broken</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, attachments); got != tt.want {
+				t.Errorf("Attachments = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package transform
+
+import (
+	"regexp"
+
+	"golang.org/x/net/html"
+)
+
+var brushLangRegex = regexp.MustCompile(`brush:\s*([^;"\s]+)`)
+
+// CodeBlocks replaces Confluence code panels
+// (<div class="code panel"> ... <pre data-syntaxhighlighter-params="brush: LANG; ...">)
+// with plain <pre><code class="language-LANG"> blocks, which Outline imports
+// as code blocks with syntax highlighting.
+type CodeBlocks struct{}
+
+func (t CodeBlocks) Name() string {
+	return "code blocks"
+}
+
+func (t CodeBlocks) Transform(doc *html.Node) error {
+	isCodePanel := func(n *html.Node) bool {
+		return n.Type == html.ElementNode && HasClass(n, "code") && HasClass(n, "panel")
+	}
+	for _, panel := range FindAll(doc, isCodePanel) {
+		if !hasAncestor(panel, func(p *html.Node) bool { return p == doc }) {
+			// Nested in a panel that was already replaced, which detached
+			// it from doc along with the panel.
+			continue
+		}
+		pre := Find(panel, ByTag("pre"))
+		if pre == nil {
+			continue
+		}
+		ReplaceWith(panel, codeBlock(pre))
+	}
+
+	// Code macros rendered without the surrounding panel.
+	isHighlighterPre := func(n *html.Node) bool {
+		return IsElement(n, "pre") && HasAttr(n, "data-syntaxhighlighter-params")
+	}
+	for _, pre := range FindAll(doc, isHighlighterPre) {
+		ReplaceWith(pre, codeBlock(pre))
+	}
+	return nil
+}
+
+// codeBlock builds a <pre><code> block holding the text of pre, tagged with
+// the language from its syntax highlighter parameters.
+func codeBlock(pre *html.Node) *html.Node {
+	lang := ""
+	if m := brushLangRegex.FindStringSubmatch(Attr(pre, "data-syntaxhighlighter-params")); len(m) > 1 {
+		lang = m[1]
+	}
+	code := Element("code")
+	if lang != "" {
+		code = Element("code", "class", "language-"+lang)
+	}
+	code.AppendChild(Text(TextContent(pre)))
+	return AppendChildren(Element("pre"), code)
+}
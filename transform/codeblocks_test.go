@@ -0,0 +1,50 @@
+package transform
+
+import "testing"
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "code panel with language",
+			body: `<div class="code panel pdl" style="border-width: 1px;"><div class="codeContent panelContent pdl">` +
+				`<pre class="syntaxhighlighter-pre" data-syntaxhighlighter-params="brush: java; gutter: false" data-theme="Confluence">if (a &lt; b) {
+}</pre></div></div><p>after</p>`,
+			want: `<pre><code class="language-java">if (a &lt; b) {
+}</code></pre><p>after</p>`,
+		},
+		{
+			name: "nested divs inside the panel",
+			body: `<div class='code panel'><div class="codeHeader"><div><b>Title</b></div></div><div class="codeContent">` +
+				`<pre data-syntaxhighlighter-params='brush: bash'>ls</pre></div></div>`,
+			want: `<pre><code class="language-bash">ls</code></pre>`,
+		},
+		{
+			name: "code panel nested in a code panel",
+			body: `<div class="code panel"><div class="codeContent"><pre data-syntaxhighlighter-params="brush: go">outer</pre>` +
+				`<div class="code panel"><pre data-syntaxhighlighter-params="brush: sh">inner</pre></div></div></div>`,
+			want: `<pre><code class="language-go">outer</code></pre>`,
+		},
+		{
+			name: "highlighter pre without panel",
+			body: `<pre data-syntaxhighlighter-params="theme: x">plain</pre>`,
+			want: `<pre><code>plain</code></pre>`,
+		},
+		{
+			name: "ordinary pre is kept",
+			body: `<pre>keep</pre>`,
+			want: `<pre>keep</pre>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, CodeBlocks{}); got != tt.want {
+				t.Errorf("CodeBlocks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
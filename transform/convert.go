@@ -0,0 +1,342 @@
+package transform
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// informationMacroClasses maps the storage format names of info, note,
+// warning and tip macros to the class the export view renders them with.
+var informationMacroClasses = map[string]string{
+	"info":    "confluence-information-macro-information",
+	"note":    "confluence-information-macro-note",
+	"warning": "confluence-information-macro-warning",
+	"tip":     "confluence-information-macro-tip",
+}
+
+// statusColourClasses maps the colour parameter of status macros to the
+// lozenge class of the export view.
+var statusColourClasses = map[string]string{
+	"green":  "aui-lozenge-success",
+	"red":    "aui-lozenge-error",
+	"yellow": "aui-lozenge-current",
+	"blue":   "aui-lozenge-complete",
+	"purple": "aui-lozenge-progress",
+}
+
+// StorageConverter converts page bodies in Confluence storage format (XHTML
+// with ac: and ri: elements) into HTML shaped like the export view, so that
+// the same transformers apply to both. Unlike the export view, storage
+// format keeps every macro with its parameters, and links and images name
+// the page or attachment they point at.
+//
+// Macros without a dedicated conversion become an element carrying
+// data-macro-name and the content of their rich text body, which is what
+// the transformers pairing macros with their storage format definition
+// match.
+type StorageConverter struct {
+	// ConfluenceBaseURL is the Confluence base URL, possibly including a
+	// context path such as /wiki. Attachments are linked below it.
+	ConfluenceBaseURL string
+	// PageID is the page whose attachments images and attachment links
+	// name. Without it, as for templates, whose attachments cannot be
+	// downloaded, they become their filename and are passed to Report.
+	PageID   string
+	SpaceKey string
+	// PageURL returns the relative Confluence URL of the page titled title
+	// in the space with key spaceKey, as Links expects it.
+	PageURL func(spaceKey, title string) string
+	// Template keeps the instructional placeholder text of a content
+	// template in italics, which pages only show while they are edited and
+	// which is dropped otherwise.
+	Template bool
+	Report   func(issue string)
+}
+
+// Convert returns the HTML document of the page titled title with the given
+// body in storage format. Like the export, the title is both the <title>
+// and a leading <h1>.
+func (c StorageConverter) Convert(title, storage string) (*html.Node, error) {
+	escapedTitle := html.EscapeString(title)
+	doc, err := parseStorage(fmt.Sprintf("<html><head><meta charset=\"utf-8\"><title>%s</title></head><body><h1>%s</h1>%s</body></html>",
+		escapedTitle,
+		escapedTitle,
+		storage,
+	))
+	if err != nil {
+		return nil, err
+	}
+	isStorageElement := func(n *html.Node) bool {
+		return n.Type == html.ElementNode && (strings.HasPrefix(n.Data, "ac:") || strings.HasPrefix(n.Data, "ri:") || strings.HasPrefix(n.Data, "at:"))
+	}
+	// Elements are converted outside in; parameters and bodies are read by
+	// the element they belong to and are gone by the time they come up.
+	for _, n := range FindAll(doc, isStorageElement) {
+		if !hasAncestor(n, func(p *html.Node) bool { return p == doc }) {
+			continue
+		}
+		c.convertElement(n)
+	}
+	return doc, nil
+}
+
+func (c StorageConverter) convertElement(n *html.Node) {
+	switch n.Data {
+	case "ac:structured-macro", "ac:macro":
+		c.convertMacro(n)
+	case "ac:image":
+		ReplaceWith(n, c.image(n))
+	case "ac:link":
+		ReplaceWith(n, c.link(n))
+	case "ac:emoticon":
+		img := Element("img", "class", "emoticon emoticon-"+Attr(n, "ac:name"), "data-emoticon-name", Attr(n, "ac:name"))
+		for _, attr := range [][2]string{{"ac:emoji-id", "data-emoji-id"}, {"ac:emoji-fallback", "data-emoji-fallback"}, {"ac:emoji-shortname", "alt"}} {
+			if value := Attr(n, attr[0]); value != "" {
+				SetAttr(img, attr[1], value)
+			}
+		}
+		ReplaceWith(n, img)
+	case "ac:task-list":
+		ReplaceWith(n, withChildrenOf(Element("ul", "class", "inline-task-list"), n))
+	case "ac:task":
+		li := withChildrenOf(Element("li"), childElement(n, "ac:task-body"))
+		if status := childElement(n, "ac:task-status"); status != nil && strings.TrimSpace(TextContent(status)) == "complete" {
+			SetAttr(li, "class", "checked")
+		}
+		ReplaceWith(n, li)
+	case "ac:layout":
+		ReplaceWith(n, withChildrenOf(Element("div", "class", "contentLayout2"), n))
+	case "ac:layout-section":
+		ReplaceWith(n, withChildrenOf(Element("div", "class", "columnLayout"), n))
+	case "ac:layout-cell":
+		ReplaceWith(n, AppendChildren(Element("div", "class", "cell"), withChildrenOf(Element("div", "class", "innerCell"), n)))
+	case "ac:adf-extension":
+		// Cloud-only elements carry a storage format fallback.
+		if fallback := Find(n, ByTag("ac:adf-fallback")); fallback != nil {
+			ReplaceWith(n, children(fallback)...)
+			return
+		}
+		Remove(n)
+	case "ac:placeholder":
+		if c.Template {
+			ReplaceWith(n, withChildrenOf(Element("em"), n))
+			return
+		}
+		Remove(n)
+	case "at:var":
+		// Outline templates have no variables; the name shows what to fill in.
+		ReplaceWith(n, Text("{"+Attr(n, "at:name")+"}"))
+	case "ac:parameter", "ac:task-id", "ac:task-status", "at:declarations":
+		Remove(n)
+	default:
+		if strings.HasPrefix(n.Data, "ri:") || strings.HasPrefix(n.Data, "at:") {
+			Remove(n)
+			return
+		}
+		// Comment markers, bodies and wrappers of unknown elements.
+		Unwrap(n)
+	}
+}
+
+func (c StorageConverter) convertMacro(macro *html.Node) {
+	name := Attr(macro, "ac:name")
+	body := childElement(macro, "ac:rich-text-body")
+
+	switch name {
+	case "code":
+		params := "brush: " + MacroParameter(macro, "language")
+		if MacroParameter(macro, "language") == "" {
+			params = "brush: none"
+		}
+		pre := AppendChildren(Element("pre", "data-syntaxhighlighter-params", params), Text(MacroBody(macro)))
+		ReplaceWith(macro, AppendChildren(Element("div", "class", "code panel"),
+			AppendChildren(Element("div", "class", "codeContent panelContent"), pre)))
+		return
+	case "noformat":
+		ReplaceWith(macro, AppendChildren(Element("pre"), Text(MacroBody(macro))))
+		return
+	case "info", "note", "warning", "tip":
+		block := Element("div", "class", "confluence-information-macro "+informationMacroClasses[name])
+		if title := MacroParameter(macro, "title"); title != "" {
+			block.AppendChild(AppendChildren(Element("p", "class", "title"), Text(title)))
+		}
+		block.AppendChild(withChildrenOf(Element("div", "class", "confluence-information-macro-body"), body))
+		ReplaceWith(macro, block)
+		return
+	case "panel":
+		panel := Element("div", "class", "panel")
+		if panelType := MacroParameter(macro, "panelType"); panelType != "" {
+			SetAttr(panel, "data-panel-type", panelType)
+		}
+		if title := MacroParameter(macro, "title"); title != "" {
+			panel.AppendChild(AppendChildren(Element("div", "class", "panelHeader"), Text(title)))
+		}
+		panel.AppendChild(withChildrenOf(Element("div", "class", "panelContent"), body))
+		ReplaceWith(macro, panel)
+		return
+	case "expand":
+		ReplaceWith(macro, AppendChildren(Element("div", "class", "expand-container", "data-macro-name", "expand"),
+			AppendChildren(Element("div", "class", "expand-control"),
+				AppendChildren(Element("span", "class", "expand-control-text"), Text(MacroParameter(macro, "title")))),
+			withChildrenOf(Element("div", "class", "expand-content"), body)))
+		return
+	case "status":
+		class := "status-macro aui-lozenge"
+		if colourClass, ok := statusColourClasses[strings.ToLower(MacroParameter(macro, "colour"))]; ok {
+			class += " " + colourClass
+		}
+		ReplaceWith(macro, AppendChildren(Element("span", "class", class, "data-macro-name", "status"), Text(MacroParameter(macro, "title"))))
+		return
+	case "toc":
+		ReplaceWith(macro, Element("div", "class", "toc-macro", "data-macro-name", "toc",
+			"data-minlevel", MacroParameter(macro, "minLevel"),
+			"data-maxlevel", MacroParameter(macro, "maxLevel")))
+		return
+	case "section":
+		ReplaceWith(macro, AppendChildren(Element("div", "class", "sectionColumnWrapper"),
+			AppendChildren(Element("div", "class", "sectionMacro"),
+				withChildrenOf(Element("div", "class", "sectionMacroRow"), body))))
+		return
+	case "column":
+		ReplaceWith(macro, withChildrenOf(Element("div", "class", "columnMacro"), body))
+		return
+	case "excerpt":
+		if body == nil {
+			Remove(macro)
+			return
+		}
+		ReplaceWith(macro, children(body)...)
+		return
+	case "anchor":
+		Remove(macro)
+		return
+	}
+
+	tag := "div"
+	if hasAncestor(macro, or(ByTag("p"), ByTag("span"), ByTag("a"), ByTag("h1"), ByTag("h2"), ByTag("h3"), ByTag("h4"), ByTag("h5"), ByTag("h6"))) {
+		tag = "span"
+	}
+	ReplaceWith(macro, withChildrenOf(Element(tag, "class", "conf-macro", "data-macro-name", name), body))
+}
+
+// image builds the <img> for an ac:image. Attachments are linked below the
+// Confluence base URL so that Images downloads them.
+func (c StorageConverter) image(image *html.Node) *html.Node {
+	src := ""
+	if attachment := Find(image, ByTag("ri:attachment")); attachment != nil {
+		filename := Attr(attachment, "ri:filename")
+		if c.PageID == "" {
+			c.reportAttachment(filename)
+			return Text("[" + filename + "]")
+		}
+		src = attachmentURL(c.ConfluenceBaseURL, c.PageID, filename)
+	} else if ref := Find(image, ByTag("ri:url")); ref != nil {
+		src = Attr(ref, "ri:value")
+	}
+	img := Element("img", "src", src)
+	if alt := Attr(image, "ac:alt"); alt != "" {
+		SetAttr(img, "alt", alt)
+	}
+	if title := Attr(image, "ac:title"); title != "" {
+		SetAttr(img, "title", title)
+	}
+	return img
+}
+
+// link builds the <a> for an ac:link. Links to another page point at its
+// /display/ URL without the anchor, which Links would not find.
+func (c StorageConverter) link(link *html.Node) *html.Node {
+	a := Element("a")
+	text := ""
+	switch {
+	case Find(link, ByTag("ri:page")) != nil, Find(link, ByTag("ri:blog-post")) != nil:
+		ref := Find(link, or(ByTag("ri:page"), ByTag("ri:blog-post")))
+		spaceKey := Attr(ref, "ri:space-key")
+		if spaceKey == "" {
+			spaceKey = c.SpaceKey
+		}
+		text = Attr(ref, "ri:content-title")
+		SetAttr(a, "href", c.PageURL(spaceKey, text))
+	case Find(link, ByTag("ri:attachment")) != nil:
+		text = Attr(Find(link, ByTag("ri:attachment")), "ri:filename")
+		if c.PageID == "" {
+			c.reportAttachment(text)
+			break
+		}
+		SetAttr(a, "href", attachmentURL(c.ConfluenceBaseURL, c.PageID, text))
+	case Find(link, ByTag("ri:user")) != nil:
+		user := Find(link, ByTag("ri:user"))
+		SetAttr(a, "class", "confluence-userlink user-mention")
+		text = "@" + firstNonEmpty(Attr(user, "ri:username"), Attr(user, "ri:userkey"), Attr(user, "ri:account-id"))
+	case Find(link, ByTag("ri:space")) != nil:
+		text = Attr(Find(link, ByTag("ri:space")), "ri:space-key")
+		SetAttr(a, "href", "/display/"+text)
+	case Find(link, ByTag("ri:url")) != nil:
+		text = Attr(Find(link, ByTag("ri:url")), "ri:value")
+		SetAttr(a, "href", text)
+	case Attr(link, "ac:anchor") != "":
+		text = Attr(link, "ac:anchor")
+		SetAttr(a, "href", "#"+text)
+	}
+
+	if body := Find(link, ByTag("ac:link-body")); body != nil {
+		return withChildrenOf(a, body)
+	}
+	if body := Find(link, ByTag("ac:plain-text-link-body")); body != nil && TextContent(body) != "" {
+		text = TextContent(body)
+	}
+	return AppendChildren(a, Text(text))
+}
+
+func (c StorageConverter) reportAttachment(filename string) {
+	if c.Report != nil {
+		c.Report(fmt.Sprintf("Attachment %q not migrated, as it cannot be downloaded", filename))
+	}
+}
+
+// attachmentURL returns the download URL of the attachment of the page with
+// id pageId with the given filename.
+func attachmentURL(confluenceBaseURL, pageId, filename string) string {
+	return strings.TrimSuffix(confluenceBaseURL, "/") + "/download/attachments/" + pageId + "/" + url.PathEscape(filename)
+}
+
+// childElement returns the first child element of n with the given tag, or
+// nil.
+func childElement(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if IsElement(c, tag) {
+			return c
+		}
+	}
+	return nil
+}
+
+// children returns the children of n.
+func children(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
+
+// withChildrenOf moves the children of from, which may be nil, into
+// container and returns container.
+func withChildrenOf(container, from *html.Node) *html.Node {
+	if from != nil {
+		MoveChildren(container, from)
+	}
+	return container
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
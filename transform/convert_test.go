@@ -0,0 +1,152 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStorageConverter(t *testing.T) {
+	converter := StorageConverter{
+		ConfluenceBaseURL: "https://wiki.example.com/wiki/",
+		PageID:            "42",
+		SpaceKey:          "ENG",
+		PageURL: func(spaceKey, title string) string {
+			return "/display/" + spaceKey + "/" + title
+		},
+	}
+
+	tests := []struct {
+		name    string
+		storage string
+		want    string
+	}{
+		{
+			name:    "code macro",
+			storage: `<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter><ac:plain-text-body><![CDATA[if a < b && c > d {}]]></ac:plain-text-body></ac:structured-macro>`,
+			want:    `<div class="code panel"><div class="codeContent panelContent"><pre data-syntaxhighlighter-params="brush: go">if a &lt; b &amp;&amp; c &gt; d {}</pre></div></div>`,
+		},
+		{
+			name:    "info macro with title",
+			storage: `<ac:structured-macro ac:name="note"><ac:parameter ac:name="title">Careful</ac:parameter><ac:rich-text-body><p>Body</p></ac:rich-text-body></ac:structured-macro>`,
+			want:    `<div class="confluence-information-macro confluence-information-macro-note"><p class="title">Careful</p><div class="confluence-information-macro-body"><p>Body</p></div></div>`,
+		},
+		{
+			name:    "expand macro",
+			storage: `<ac:structured-macro ac:name="expand"><ac:parameter ac:name="title">More</ac:parameter><ac:rich-text-body><p>Hidden</p></ac:rich-text-body></ac:structured-macro>`,
+			want:    `<div class="expand-container" data-macro-name="expand"><div class="expand-control"><span class="expand-control-text">More</span></div><div class="expand-content"><p>Hidden</p></div></div>`,
+		},
+		{
+			name:    "inline status macro",
+			storage: `<p>State: <ac:structured-macro ac:name="status"><ac:parameter ac:name="colour">Green</ac:parameter><ac:parameter ac:name="title">DONE</ac:parameter></ac:structured-macro></p>`,
+			want:    `<p>State: <span class="status-macro aui-lozenge aui-lozenge-success" data-macro-name="status">DONE</span></p>`,
+		},
+		{
+			name:    "unknown macros keep their body",
+			storage: `<ac:structured-macro ac:name="jira"><ac:parameter ac:name="key">ENG-1</ac:parameter></ac:structured-macro><ac:structured-macro ac:name="custom"><ac:rich-text-body><p>Kept</p></ac:rich-text-body></ac:structured-macro>`,
+			want:    `<div class="conf-macro" data-macro-name="jira"></div><div class="conf-macro" data-macro-name="custom"><p>Kept</p></div>`,
+		},
+		{
+			name:    "attachment image",
+			storage: `<ac:image ac:alt="Diagram"><ri:attachment ri:filename="my diagram.png"/></ac:image>`,
+			want:    `<img src="https://wiki.example.com/wiki/download/attachments/42/my%20diagram.png" alt="Diagram"/>`,
+		},
+		{
+			name:    "page links",
+			storage: `<p><ac:link><ri:page ri:content-title="Setup"/><ac:plain-text-link-body><![CDATA[see setup]]></ac:plain-text-link-body></ac:link> and <ac:link><ri:page ri:space-key="OPS" ri:content-title="Runbook"/></ac:link></p>`,
+			want:    `<p><a href="/display/ENG/Setup">see setup</a> and <a href="/display/OPS/Runbook">Runbook</a></p>`,
+		},
+		{
+			name:    "self-closing elements do not swallow what follows",
+			storage: `<p>Hi <ac:emoticon ac:name="smile"/> there</p>`,
+			want:    `<p>Hi <img class="emoticon emoticon-smile" data-emoticon-name="smile"/> there</p>`,
+		},
+		{
+			name:    "task list",
+			storage: `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>complete</ac:task-status><ac:task-body>Ship it <ac:link><ri:user ri:username="ann"/></ac:link></ac:task-body></ac:task></ac:task-list>`,
+			want:    `<ul class="inline-task-list"><li class="checked">Ship it <a class="confluence-userlink user-mention">@ann</a></li></ul>`,
+		},
+		{
+			name:    "page layout",
+			storage: `<ac:layout><ac:layout-section ac:type="two_equal"><ac:layout-cell><p>Left</p></ac:layout-cell><ac:layout-cell><p>Right</p></ac:layout-cell></ac:layout-section></ac:layout>`,
+			want:    `<div class="contentLayout2"><div class="columnLayout"><div class="cell"><div class="innerCell"><p>Left</p></div></div><div class="cell"><div class="innerCell"><p>Right</p></div></div></div></div>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := converter.Convert("Title", tt.storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "<h1>Title</h1>" + tt.want
+			if got := renderBody(t, doc); got != want {
+				t.Errorf("Convert() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestStorageConverterTemplate(t *testing.T) {
+	storage := `<at:declarations><at:string at:name="Owner"/></at:declarations><p>Owner: <at:var at:name="Owner"/></p><p><ac:placeholder>Describe the decision</ac:placeholder></p>` +
+		`<p>From <at:var at:name="Start"/> to <at:var at:name="End"/> done</p>`
+	tests := []struct {
+		name     string
+		template bool
+		want     string
+	}{
+		{
+			name:     "template",
+			template: true,
+			want:     `<h1>Meeting notes</h1><p>Owner: {Owner}</p><p><em>Describe the decision</em></p><p>From {Start} to {End} done</p>`,
+		},
+		{
+			name: "page",
+			want: `<h1>Meeting notes</h1><p>Owner: {Owner}</p><p></p><p>From {Start} to {End} done</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := StorageConverter{Template: tt.template}.Convert("Meeting notes", storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := renderBody(t, doc); got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorageConverterWithoutPageID(t *testing.T) {
+	var issues []string
+	converter := StorageConverter{Report: func(issue string) { issues = append(issues, issue) }}
+	doc, err := converter.Convert("Title", `<p><ac:image><ri:attachment ri:filename="logo.png"/></ac:image> `+
+		`<ac:link><ri:attachment ri:filename="spec.pdf"/></ac:link></p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := renderBody(t, doc), `<h1>Title</h1><p>[logo.png] <a>spec.pdf</a></p>`; got != want {
+		t.Errorf("Convert() = %q, want %q", got, want)
+	}
+	want := []string{`Attachment "logo.png" not migrated, as it cannot be downloaded`, `Attachment "spec.pdf" not migrated, as it cannot be downloaded`}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("reported %q, want %q", issues, want)
+	}
+}
+
+// The converted HTML is shaped like the export view, so the transformers
+// written for the export view apply to it unchanged.
+func TestStorageConverterFeedsPipeline(t *testing.T) {
+	doc, err := StorageConverter{PageID: "1"}.Convert("Title",
+		`<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">sh</ac:parameter><ac:plain-text-body><![CDATA[ls]]></ac:plain-text-body></ac:structured-macro>`+
+			`<ac:structured-macro ac:name="tip"><ac:rich-text-body><p>Tip <ac:emoticon ac:name="thumbs-up"/></p></ac:rich-text-body></ac:structured-macro>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (Pipeline{Emoticons{}, CodeBlocks{}, Notices{}}).Run(doc); err != nil {
+		t.Fatal(err)
+	}
+	want := `<h1>Title</h1><pre><code class="language-sh">ls</code></pre><div class="notice-block tip"><div class="content"><p>Tip 👍</p></div></div>`
+	if got := renderBody(t, doc); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,246 @@
+package transform
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DiagramReference is a diagram macro as stored in the page: the attachment
+// name of a draw.io or Gliffy diagram, or the source of a Mermaid or
+// PlantUML diagram.
+type DiagramReference struct {
+	Macro  string
+	Name   string
+	Source string
+}
+
+// diagramMacros maps the names of diagram macros to the kind of diagram they
+// hold.
+var diagramMacros = map[string]string{
+	"drawio":            "drawio",
+	"inc-drawio":        "drawio",
+	"drawio-sketch":     "drawio",
+	"gliffy":            "gliffy",
+	"mermaid":           "mermaid",
+	"mermaid-macro":     "mermaid",
+	"mermaid-cloud":     "mermaid",
+	"plantuml":          "plantuml",
+	"plantumlrender":    "plantuml",
+	"plantuml-renderer": "plantuml",
+}
+
+// diagramClasses identifies rendered diagram macros that carry no
+// data-macro-name.
+var diagramClasses = map[string]string{
+	"drawio-macro":     "drawio",
+	"gliffy-container": "gliffy",
+	"gliffy-macro":     "gliffy",
+}
+
+// ParseDiagramReferences lists the diagram macros of a page in storage
+// format, in document order.
+func ParseDiagramReferences(storage string) ([]DiagramReference, error) {
+	var names []string
+	for name := range diagramMacros {
+		names = append(names, name)
+	}
+	macros, err := StorageMacros(storage, names...)
+	if err != nil {
+		return nil, err
+	}
+	var refs []DiagramReference
+	for _, macro := range macros {
+		ref := DiagramReference{Macro: diagramMacros[Attr(macro, "ac:name")]}
+		switch ref.Macro {
+		case "drawio":
+			ref.Name = MacroParameter(macro, "diagramName")
+		case "gliffy":
+			ref.Name = MacroParameter(macro, "name")
+		default:
+			ref.Source = MacroBody(macro)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// Diagrams replaces rendered diagram macros. draw.io and Gliffy diagrams
+// become their PNG (or SVG) preview attachment, stored with Store, followed
+// by a link to the stored source file so that the diagram can be edited
+// later. Mermaid diagrams become mermaidjs code blocks, which Outline
+// renders. PlantUML diagrams become plantuml code blocks, preceded by the
+// diagram rendered by PlantUMLServer when one is set.
+//
+// The i-th rendered macro of a kind is paired with the i-th reference of the
+// same kind; when the counts of a kind differ, its macros are kept as
+// exported. Diagrams whose preview cannot be fetched are kept as exported.
+type Diagrams struct {
+	References []DiagramReference
+	// Attachment downloads an attachment of the page by filename.
+	Attachment func(filename string) ([]byte, string, error)
+	// Download fetches a URL, used for PlantUML rendering.
+	Download       func(url string) ([]byte, string, error)
+	Store          func(data []byte, filename string, contentType string) (string, error)
+	PlantUMLServer string
+	Logger         *slog.Logger
+}
+
+func (t Diagrams) Name() string {
+	return "diagrams"
+}
+
+// renderedDiagramKind returns the kind of diagram n renders, or "".
+func renderedDiagramKind(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	if kind, ok := diagramMacros[Attr(n, "data-macro-name")]; ok {
+		return kind
+	}
+	for class, kind := range diagramClasses {
+		if HasClass(n, class) {
+			return kind
+		}
+	}
+	return ""
+}
+
+func (t Diagrams) Transform(doc *html.Node) error {
+	refsByKind := make(map[string][]DiagramReference)
+	for _, ref := range t.References {
+		refsByKind[ref.Macro] = append(refsByKind[ref.Macro], ref)
+	}
+	macrosByKind := make(map[string][]*html.Node)
+	isDiagram := func(n *html.Node) bool {
+		return renderedDiagramKind(n) != ""
+	}
+	for _, macro := range renderedMacros(doc, isDiagram) {
+		kind := renderedDiagramKind(macro)
+		macrosByKind[kind] = append(macrosByKind[kind], macro)
+	}
+	for _, kind := range []string{"drawio", "gliffy", "mermaid", "plantuml"} {
+		macros := macrosByKind[kind]
+		refs := refsByKind[kind]
+		paired := pairMacros(macros, len(refs), func(int) bool { return true }, kind, t.Logger)
+		for i, macro := range macros[:paired] {
+			ref := refs[i]
+			var replacement []*html.Node
+			switch kind {
+			case "drawio", "gliffy":
+				replacement = t.attachedDiagram(ref)
+			case "mermaid":
+				replacement = []*html.Node{diagramCodeBlock("mermaidjs", ref.Source)}
+			case "plantuml":
+				replacement = t.plantUML(ref, fmt.Sprintf("plantuml-%d.png", i+1))
+			}
+			if replacement != nil {
+				ReplaceWith(macro, replacement...)
+			}
+		}
+	}
+	return nil
+}
+
+// attachedDiagram renders a draw.io or Gliffy diagram as its stored preview
+// and a link to its stored source, or returns nil if there is no preview.
+func (t Diagrams) attachedDiagram(ref DiagramReference) []*html.Node {
+	if ref.Name == "" {
+		return nil
+	}
+	var previewURL string
+	for _, ext := range []string{".png", ".svg"} {
+		data, contentType, err := t.Attachment(ref.Name + ext)
+		if err != nil {
+			continue
+		}
+		previewURL, err = t.Store(data, ref.Name+ext, contentType)
+		if err != nil {
+			t.Logger.Warn("Failed to store diagram preview", "diagram", ref.Name, "error", err)
+			return nil
+		}
+		break
+	}
+	if previewURL == "" {
+		t.Logger.Warn("No preview attachment found for diagram, kept as exported", "macro", ref.Macro, "diagram", ref.Name)
+		return nil
+	}
+	nodes := []*html.Node{AppendChildren(Element("p"), Element("img", "src", previewURL, "alt", ref.Name))}
+
+	// draw.io stores the source without an extension, newer versions with
+	// one; Gliffy always without.
+	sourceFilename := ref.Name + "." + ref.Macro
+	for _, filename := range []string{ref.Name, sourceFilename} {
+		data, contentType, err := t.Attachment(filename)
+		if err != nil {
+			continue
+		}
+		sourceURL, err := t.Store(data, sourceFilename, contentType)
+		if err != nil {
+			t.Logger.Warn("Failed to store diagram source", "diagram", ref.Name, "error", err)
+			break
+		}
+		nodes = append(nodes, AppendChildren(Element("p"),
+			Text("Diagram source: "),
+			AppendChildren(Element("a", "href", sourceURL), Text(sourceFilename))))
+		break
+	}
+	return nodes
+}
+
+// plantUML renders a PlantUML diagram as a code block, preceded by the
+// rendered image, stored as filename, when a PlantUML server is configured.
+func (t Diagrams) plantUML(ref DiagramReference, filename string) []*html.Node {
+	code := diagramCodeBlock("plantuml", ref.Source)
+	if t.PlantUMLServer == "" || strings.TrimSpace(ref.Source) == "" {
+		return []*html.Node{code}
+	}
+	imageURL := strings.TrimSuffix(t.PlantUMLServer, "/") + "/png/" + encodePlantUML(ref.Source)
+	data, contentType, err := t.Download(imageURL)
+	if err != nil {
+		t.Logger.Warn("Failed to render PlantUML diagram", "error", err)
+		return []*html.Node{code}
+	}
+	storedURL, err := t.Store(data, filename, contentType)
+	if err != nil {
+		t.Logger.Warn("Failed to store PlantUML diagram", "error", err)
+		return []*html.Node{code}
+	}
+	return []*html.Node{
+		AppendChildren(Element("p"), Element("img", "src", storedURL, "alt", "PlantUML diagram")),
+		code,
+	}
+}
+
+func diagramCodeBlock(lang, source string) *html.Node {
+	code := AppendChildren(Element("code", "class", "language-"+lang), Text(strings.Trim(source, "\n")))
+	return AppendChildren(Element("pre"), code)
+}
+
+// plantUMLAlphabet is the base64 variant PlantUML servers decode.
+const plantUMLAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"
+
+// encodePlantUML encodes a diagram for a PlantUML server URL: raw deflate,
+// then PlantUML's base64 variant.
+func encodePlantUML(source string) string {
+	var compressed bytes.Buffer
+	w, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	w.Write([]byte(source))
+	w.Close()
+
+	data := compressed.Bytes()
+	var b strings.Builder
+	for i := 0; i < len(data); i += 3 {
+		var chunk [3]byte
+		copy(chunk[:], data[i:])
+		b.WriteByte(plantUMLAlphabet[chunk[0]>>2])
+		b.WriteByte(plantUMLAlphabet[(chunk[0]&0x3)<<4|chunk[1]>>4])
+		b.WriteByte(plantUMLAlphabet[(chunk[1]&0xF)<<2|chunk[2]>>6])
+		b.WriteByte(plantUMLAlphabet[chunk[2]&0x3F])
+	}
+	return b.String()
+}
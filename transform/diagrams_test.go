@@ -0,0 +1,132 @@
+package transform
+
+import (
+	"compress/flate"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiagramReferences(t *testing.T) {
+	storage := `<ac:structured-macro ac:name="drawio"><ac:parameter ac:name="diagramName">Architecture</ac:parameter><ac:parameter ac:name="revision">3</ac:parameter></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="gliffy"><ac:parameter ac:name="name">Flow</ac:parameter></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="mermaid-cloud"><ac:plain-text-body><![CDATA[graph TD
+  A --> B]]></ac:plain-text-body></ac:structured-macro>`
+	got, err := ParseDiagramReferences(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiagramReference{
+		{Macro: "drawio", Name: "Architecture"},
+		{Macro: "gliffy", Name: "Flow"},
+		{Macro: "mermaid", Source: "graph TD\n  A --> B"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDiagramReferences() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiagrams(t *testing.T) {
+	attachments := map[string]string{
+		"Architecture.png": "image/png",
+		"Architecture":     "application/vnd.jgraph.mxfile",
+		"Flow.svg":         "image/svg+xml",
+	}
+	diagrams := Diagrams{
+		References: []DiagramReference{
+			{Macro: "drawio", Name: "Architecture"},
+			{Macro: "mermaid", Source: "graph TD\n  A --> B\n"},
+			{Macro: "gliffy", Name: "Flow"},
+			{Macro: "drawio", Name: "Missing"},
+			{Macro: "plantuml", Source: "Bob -> Alice"},
+		},
+		Attachment: func(filename string) ([]byte, string, error) {
+			contentType, ok := attachments[filename]
+			if !ok {
+				return nil, "", errors.New("404")
+			}
+			return []byte(filename), contentType, nil
+		},
+		Store: func(data []byte, filename string, contentType string) (string, error) {
+			return "/stored/" + filename, nil
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	body := `<div class="conf-macro output-block" data-macro-name="drawio"><img src="/preview.png"/></div>` +
+		`<div data-macro-name="mermaid-cloud">loading</div>` +
+		`<div class="gliffy-container"><img src="/gliffy.png"/></div>` +
+		`<div data-macro-name="drawio">old</div>` +
+		`<div data-macro-name="plantuml">img</div>`
+	want := `<p><img src="/stored/Architecture.png" alt="Architecture"/></p>` +
+		`<p>Diagram source: <a href="/stored/Architecture.drawio">Architecture.drawio</a></p>` +
+		`<pre><code class="language-mermaidjs">graph TD
+  A --&gt; B</code></pre>` +
+		`<p><img src="/stored/Flow.svg" alt="Flow"/></p>` +
+		`<div data-macro-name="drawio">old</div>` +
+		`<pre><code class="language-plantuml">Bob -&gt; Alice</code></pre>`
+	if got := transformBody(t, body, diagrams); got != want {
+		t.Errorf("Diagrams = %q, want %q", got, want)
+	}
+}
+
+func TestEncodePlantUML(t *testing.T) {
+	source := "@startuml\nBob -> Alice : hello\n@enduml"
+	encoded := encodePlantUML(source)
+
+	// Decode with the inverse alphabet and inflate.
+	var data []byte
+	for i := 0; i < len(encoded); i += 4 {
+		var c [4]byte
+		for j := 0; j < 4; j++ {
+			c[j] = byte(strings.IndexByte(plantUMLAlphabet, encoded[i+j]))
+		}
+		data = append(data, c[0]<<2|c[1]>>4, c[1]<<4|c[2]>>2, c[2]<<6|c[3])
+	}
+	decoded, err := io.ReadAll(flate.NewReader(strings.NewReader(string(data))))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal(err)
+	}
+	if string(decoded) != source {
+		t.Errorf("decoded %q, want %q", decoded, source)
+	}
+}
+
+func TestDiagramsPlantUMLNames(t *testing.T) {
+	diagrams := Diagrams{
+		References: []DiagramReference{
+			{Macro: "plantuml", Source: "A -> B"},
+			{Macro: "plantuml", Source: "B -> C"},
+		},
+		Download: func(url string) ([]byte, string, error) {
+			return []byte(url), "image/png", nil
+		},
+		Store: func(data []byte, filename string, contentType string) (string, error) {
+			return "/stored/" + filename, nil
+		},
+		PlantUMLServer: "https://plantuml.example.com",
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	body := `<div data-macro-name="plantuml">one</div><div data-macro-name="plantuml">two</div>`
+	want := `<p><img src="/stored/plantuml-1.png" alt="PlantUML diagram"/></p>` +
+		`<pre><code class="language-plantuml">A -&gt; B</code></pre>` +
+		`<p><img src="/stored/plantuml-2.png" alt="PlantUML diagram"/></p>` +
+		`<pre><code class="language-plantuml">B -&gt; C</code></pre>`
+	if got := transformBody(t, body, diagrams); got != want {
+		t.Errorf("Diagrams = %q, want %q", got, want)
+	}
+}
+
+func TestDiagramsCountMismatch(t *testing.T) {
+	diagrams := Diagrams{
+		// A mermaid macro from an included page has no reference here.
+		References: []DiagramReference{{Macro: "mermaid", Source: "graph TD"}},
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	body := `<div data-macro-name="mermaid-cloud">included</div><div data-macro-name="mermaid-cloud">own</div>`
+	if got := transformBody(t, body, diagrams); got != body {
+		t.Errorf("Diagrams = %q, want %q", got, body)
+	}
+}
@@ -0,0 +1,105 @@
+package transform
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// emoticonEmoji maps the names of Confluence's built-in emoticons to
+// Unicode emoji.
+var emoticonEmoji = map[string]string{
+	"smile":        "🙂",
+	"sad":          "🙁",
+	"cheeky":       "😛",
+	"laugh":        "😃",
+	"wink":         "😉",
+	"thumbs-up":    "👍",
+	"thumbs-down":  "👎",
+	"information":  "ℹ️",
+	"tick":         "✅",
+	"cross":        "❌",
+	"warning":      "⚠️",
+	"plus":         "➕",
+	"minus":        "➖",
+	"question":     "❓",
+	"light-on":     "💡",
+	"light-off":    "💡",
+	"yellow-star":  "⭐",
+	"red-star":     "⭐",
+	"green-star":   "⭐",
+	"blue-star":    "⭐",
+	"heart":        "❤️",
+	"broken-heart": "💔",
+}
+
+// Emoticons replaces Confluence emoticon and emoji images with Unicode
+// emoji, so that Images does not try to re-upload Confluence's icon files.
+// Cloud emoji carry their Unicode form in data-emoji-fallback or as code
+// points in data-emoji-id; classic emoticons are looked up by name. Unknown
+// emoticons are replaced by their alt text.
+type Emoticons struct{}
+
+func (t Emoticons) Name() string {
+	return "emoticons"
+}
+
+func (t Emoticons) Transform(doc *html.Node) error {
+	isEmoticon := func(n *html.Node) bool {
+		return IsElement(n, "img") &&
+			(HasClass(n, "emoticon") || HasAttr(n, "data-emoticon-name") || HasAttr(n, "data-emoji-id"))
+	}
+	for _, img := range FindAll(doc, isEmoticon) {
+		emoji := emoticonText(img)
+		if emoji == "" {
+			Remove(img)
+			continue
+		}
+		ReplaceWith(img, Text(emoji))
+	}
+	return nil
+}
+
+// emoticonText returns the text to replace an emoticon image with.
+func emoticonText(img *html.Node) string {
+	if fallback := Attr(img, "data-emoji-fallback"); fallback != "" && !strings.HasPrefix(fallback, ":") {
+		return fallback
+	}
+	if emoji := emojiFromCodePoints(Attr(img, "data-emoji-id")); emoji != "" {
+		return emoji
+	}
+	name := Attr(img, "data-emoticon-name")
+	if id := Attr(img, "data-emoji-id"); strings.HasPrefix(id, "atlassian-") {
+		// Cloud names the classic emoticons e.g. atlassian-thumbs_up.
+		name = strings.ReplaceAll(strings.TrimPrefix(id, "atlassian-"), "_", "-")
+	}
+	if name == "" {
+		for _, class := range strings.Fields(Attr(img, "class")) {
+			if strings.HasPrefix(class, "emoticon-") {
+				name = strings.TrimPrefix(class, "emoticon-")
+			}
+		}
+	}
+	if emoji, ok := emoticonEmoji[name]; ok {
+		return emoji
+	}
+	return Attr(img, "alt")
+}
+
+// emojiFromCodePoints decodes an emoji id such as "1f44d" or "1f1fa-1f1f8",
+// returning "" if id is not made of hexadecimal code points.
+func emojiFromCodePoints(id string) string {
+	if id == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range strings.Split(id, "-") {
+		codePoint, err := strconv.ParseUint(part, 16, 32)
+		if err != nil || codePoint < 0x80 {
+			return ""
+		}
+		b.WriteRune(rune(codePoint))
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package transform
+
+import "testing"
+
+func TestEmoticons(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "server emoticon by name",
+			body: `<p>Nice <img class="emoticon emoticon-thumbs-up" data-emoticon-name="thumbs-up" src="/images/icons/emoticons/thumbs_up.svg" alt="(thumbs up)"/></p>`,
+			want: `<p>Nice 👍</p>`,
+		},
+		{
+			name: "emoticon by class",
+			body: `<img class="emoticon emoticon-tick" src="/images/icons/emoticons/check.svg"/>`,
+			want: `✅`,
+		},
+		{
+			name: "cloud emoji fallback",
+			body: `<img class="emoticon" data-emoji-id="1f680" data-emoji-shortname=":rocket:" data-emoji-fallback="🚀" src="x.png"/>`,
+			want: `🚀`,
+		},
+		{
+			name: "cloud emoji code points",
+			body: `<img data-emoji-id="1f1fa-1f1f8" data-emoji-shortname=":flag_us:" src="x.png"/>`,
+			want: `🇺🇸`,
+		},
+		{
+			name: "cloud classic emoticon",
+			body: `<img class="emoticon" data-emoji-id="atlassian-thumbs_down" data-emoji-fallback=":thumbsdown:" src="x.png"/>`,
+			want: `👎`,
+		},
+		{
+			name: "unknown emoticon uses alt text",
+			body: `<img class="emoticon emoticon-custom" alt="(party)" src="x.png"/>`,
+			want: `(party)`,
+		},
+		{
+			name: "ordinary image is kept",
+			body: `<img src="/download/attachments/1/a.png"/>`,
+			want: `<img src="/download/attachments/1/a.png"/>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, Emoticons{}); got != tt.want {
+				t.Errorf("Emoticons = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
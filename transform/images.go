@@ -0,0 +1,97 @@
+package transform
+
+import (
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Images downloads every Confluence-hosted <img>, hands the bytes to Store
+// and points the src at the location Store returns. Images hosted elsewhere
+// are left alone. Images that fail to download or store keep their original
+// src.
+type Images struct {
+	// ConfluenceBaseURL is the Confluence base URL, possibly including a
+	// context path such as /wiki.
+	ConfluenceBaseURL string
+	Download          func(imageUrl string) ([]byte, string, error)
+	Store             func(imageData []byte, filename string, contentType string) (string, error)
+	Logger            *slog.Logger
+}
+
+func (t Images) Name() string {
+	return "images"
+}
+
+func (t Images) Transform(doc *html.Node) error {
+	confluenceBase := strings.TrimSuffix(t.ConfluenceBaseURL, "/")
+
+	// For resolving absolute paths (starting with "/"), use only the scheme+host
+	// to avoid double-path like /wiki/wiki/... when confluenceBase already contains a path.
+	confluenceOrigin := confluenceBase
+	if u, err := url.Parse(confluenceBase); err == nil {
+		confluenceOrigin = u.Scheme + "://" + u.Host
+	}
+
+	// The same image is often embedded several times; fetch it once.
+	stored := make(map[string]string)
+
+	for _, img := range FindAll(doc, ByTag("img")) {
+		imgSrc := Attr(img, "src")
+		imgURL := resolveConfluenceImageURL(imgSrc, confluenceBase, confluenceOrigin)
+		if imgURL == "" {
+			continue
+		}
+
+		storedURL, ok := stored[imgURL]
+		if !ok {
+			// Strip query params for the filename
+			imgFilename := path.Base(strings.SplitN(imgSrc, "?", 2)[0])
+			if imgFilename == "" || imgFilename == "." || imgFilename == "/" {
+				imgFilename = "image.png"
+			}
+
+			imageData, contentType, err := t.Download(imgURL)
+			if err != nil {
+				t.Logger.Warn("Failed to download image", "url", imgURL, "error", err)
+				continue
+			}
+			storedURL, err = t.Store(imageData, imgFilename, contentType)
+			if err != nil {
+				t.Logger.Warn("Failed to store image", "url", imgURL, "error", err)
+				continue
+			}
+			stored[imgURL] = storedURL
+		}
+
+		SetAttr(img, "src", storedURL)
+		// Responsive variants and Confluence's own copies of the URL would
+		// still point at Confluence.
+		RemoveAttr(img, "srcset")
+		RemoveAttr(img, "data-image-src")
+	}
+	return nil
+}
+
+// resolveConfluenceImageURL returns the absolute URL to download imgSrc from,
+// or "" if the image is not hosted by Confluence or Atlassian media.
+func resolveConfluenceImageURL(imgSrc, confluenceBase, confluenceOrigin string) string {
+	switch {
+	case strings.HasPrefix(imgSrc, "http://") || strings.HasPrefix(imgSrc, "https://"):
+		isConfluence := strings.HasPrefix(imgSrc, confluenceBase)
+		isAtlassianMedia := strings.HasPrefix(imgSrc, "https://api.media.atlassian.com/")
+		if !isConfluence && !isAtlassianMedia {
+			return ""
+		}
+		return imgSrc
+	case strings.HasPrefix(imgSrc, "//"):
+		return ""
+	case strings.HasPrefix(imgSrc, "/"):
+		return confluenceOrigin + imgSrc
+	default:
+		return ""
+	}
+}
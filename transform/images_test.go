@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestImages(t *testing.T) {
+	var downloaded []string
+	images := Images{
+		ConfluenceBaseURL: "https://example.atlassian.net/wiki",
+		Download: func(imageUrl string) ([]byte, string, error) {
+			downloaded = append(downloaded, imageUrl)
+			if imageUrl == "https://example.atlassian.net/download/broken.png" {
+				return nil, "", errors.New("404")
+			}
+			return []byte("png"), "image/png", nil
+		},
+		Store: func(imageData []byte, filename string, contentType string) (string, error) {
+			return "/api/attachments.redirect?id=" + filename, nil
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "relative src resolves against the origin",
+			body: `<img src="/wiki/download/attachments/1/diagram.png?version=2" srcset="/wiki/a.png 2x" data-image-src="/wiki/a.png"/>`,
+			want: `<img src="/api/attachments.redirect?id=diagram.png"/>`,
+		},
+		{
+			name: "single-quoted absolute src",
+			body: `<img class='x' src='https://example.atlassian.net/wiki/download/thumbnails/1/shot.png'/>`,
+			want: `<img class="x" src="/api/attachments.redirect?id=shot.png"/>`,
+		},
+		{
+			name: "external image is kept",
+			body: `<img src="https://cdn.example.com/logo.png"/>`,
+			want: `<img src="https://cdn.example.com/logo.png"/>`,
+		},
+		{
+			name: "failed download keeps the original src",
+			body: `<img src="/download/broken.png"/>`,
+			want: `<img src="/download/broken.png"/>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, images); got != tt.want {
+				t.Errorf("Images = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImagesDownloadsRepeatedImageOnce(t *testing.T) {
+	downloads := 0
+	images := Images{
+		ConfluenceBaseURL: "https://confluence.example.com",
+		Download: func(imageUrl string) ([]byte, string, error) {
+			downloads++
+			return []byte("png"), "image/png", nil
+		},
+		Store: func(imageData []byte, filename string, contentType string) (string, error) {
+			return "/stored/" + filename, nil
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	got := transformBody(t, `<img src="/a.png"/><img src="/a.png"/>`, images)
+	if got != `<img src="/stored/a.png"/><img src="/stored/a.png"/>` {
+		t.Errorf("Images = %q", got)
+	}
+	if downloads != 1 {
+		t.Errorf("downloaded %d times, want 1", downloads)
+	}
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"log/slog"
+
+	"golang.org/x/net/html"
+)
+
+// IncludeReference is a page transcluded by an include or excerpt-include
+// macro.
+type IncludeReference struct {
+	Macro    string
+	Title    string
+	SpaceKey string
+}
+
+// includedAttr marks the elements Includes inlined, so that the steps after
+// it do not pair the macros of the included page with those of the page.
+const includedAttr = "data-included-from"
+
+// Include modes of the Includes transformer.
+const (
+	IncludeInline = "inline"
+	IncludeLink   = "link"
+)
+
+// ParseIncludeReferences lists the pages transcluded by the include and
+// excerpt-include macros of a page in storage format, in document order.
+func ParseIncludeReferences(storage string) ([]IncludeReference, error) {
+	macros, err := StorageMacros(storage, "include", "excerpt-include")
+	if err != nil {
+		return nil, err
+	}
+	var refs []IncludeReference
+	for _, macro := range macros {
+		ref := IncludeReference{Macro: Attr(macro, "ac:name")}
+		if page := Find(macro, ByTag("ri:page")); page != nil {
+			ref.Title = Attr(page, "ri:content-title")
+			ref.SpaceKey = Attr(page, "ri:space-key")
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// Includes handles the rendered include and excerpt-include macros of a
+// page. The export view already holds the current content of the included
+// page; in IncludeInline mode it is kept and followed by an "Included from"
+// link, in IncludeLink mode it is replaced by a link to the included page.
+// References are paired with the rendered macros by position, as the export
+// view does not name the included page. Pairing stops at the first rendered
+// macro of another kind than its reference, and no macro is paired if the
+// counts differ; unpaired macros are kept as exported.
+type Includes struct {
+	Mode       string
+	References []IncludeReference
+	// PageURL returns the URL to link to for an included page.
+	PageURL func(ref IncludeReference) string
+	Logger  *slog.Logger
+}
+
+func (t Includes) Name() string {
+	return "includes"
+}
+
+func (t Includes) Transform(doc *html.Node) error {
+	macros := renderedMacros(doc, or(ByMacro("include"), ByMacro("excerpt-include")))
+	paired := pairMacros(macros, len(t.References), func(i int) bool {
+		return Attr(macros[i], "data-macro-name") == t.References[i].Macro
+	}, "include", t.Logger)
+	for i, macro := range macros[:paired] {
+		ref := t.References[i]
+		if ref.Title == "" {
+			continue
+		}
+		link := AppendChildren(Element("a", "href", t.PageURL(ref)), Text(ref.Title))
+		if t.Mode == IncludeLink {
+			ReplaceWith(macro, AppendChildren(Element("p"), link))
+			continue
+		}
+
+		content := macro
+		// excerpt-include wraps the excerpt in a panel titled with the page
+		// name unless nopanel is set.
+		if panelContent := Find(macro, ByClass("panelContent")); panelContent != nil && ref.Macro == "excerpt-include" {
+			content = panelContent
+		}
+		var included []*html.Node
+		for c := content.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				SetAttr(c, includedAttr, ref.Title)
+			}
+			included = append(included, c)
+		}
+		included = append(included, AppendChildren(Element("p"),
+			AppendChildren(Element("em"), Text("Included from "), link)))
+		ReplaceWith(macro, included...)
+	}
+	return nil
+}
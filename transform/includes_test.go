@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestParseIncludeReferences(t *testing.T) {
+	storage := `<p>Intro</p>` +
+		`<ac:structured-macro ac:name="include" ac:schema-version="1"><ac:parameter ac:name=""><ac:link><ri:page ri:content-title="Shared steps" /></ac:link></ac:parameter></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="info"><ac:rich-text-body><p>x</p></ac:rich-text-body></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="excerpt-include"><ac:parameter ac:name="nopanel">true</ac:parameter>` +
+		`<ac:parameter ac:name=""><ac:link><ri:page ri:space-key="OPS" ri:content-title="On-call: rota" /></ac:link></ac:parameter></ac:structured-macro>`
+	got, err := ParseIncludeReferences(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IncludeReference{
+		{Macro: "include", Title: "Shared steps"},
+		{Macro: "excerpt-include", Title: "On-call: rota", SpaceKey: "OPS"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseIncludeReferences() = %+v, want %+v", got, want)
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	refs := []IncludeReference{
+		{Macro: "include", Title: "Shared steps"},
+		{Macro: "excerpt-include", Title: "Rota", SpaceKey: "OPS"},
+	}
+	pageURL := func(ref IncludeReference) string {
+		return "/display/" + ref.SpaceKey + "/" + ref.Title
+	}
+	body := `<div class="conf-macro output-block" data-macro-name="include"><p>Step 1</p>` +
+		`<div data-macro-name="include"><p>nested</p></div></div>` +
+		`<div class="conf-macro output-block" data-macro-name="excerpt-include"><div class="panel"><div class="panelHeader"><b>Rota</b></div>` +
+		`<div class="panelContent"><p>Alice</p></div></div></div>`
+
+	tests := []struct {
+		name string
+		mode string
+		refs []IncludeReference
+		want string
+	}{
+		{
+			name: "inline keeps content and links the source",
+			mode: IncludeInline,
+			want: `<p data-included-from="Shared steps">Step 1</p><div data-macro-name="include" data-included-from="Shared steps"><p>nested</p></div>` +
+				`<p><em>Included from <a href="/display//Shared steps">Shared steps</a></em></p>` +
+				`<p data-included-from="Rota">Alice</p><p><em>Included from <a href="/display/OPS/Rota">Rota</a></em></p>`,
+		},
+		{
+			name: "link replaces content",
+			mode: IncludeLink,
+			want: `<p><a href="/display//Shared steps">Shared steps</a></p><p><a href="/display/OPS/Rota">Rota</a></p>`,
+		},
+		{
+			name: "pairing stops at a macro of another kind",
+			mode: IncludeLink,
+			refs: []IncludeReference{
+				{Macro: "include", Title: "Shared steps"},
+				{Macro: "include", Title: "Rota"},
+			},
+			want: `<p><a href="/display//Shared steps">Shared steps</a></p>` +
+				`<div class="conf-macro output-block" data-macro-name="excerpt-include"><div class="panel"><div class="panelHeader"><b>Rota</b></div>` +
+				`<div class="panelContent"><p>Alice</p></div></div></div>`,
+		},
+		{
+			name: "nothing is paired when the counts differ",
+			mode: IncludeLink,
+			refs: refs[:1],
+			want: body,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			includes := Includes{
+				Mode:       tt.mode,
+				References: refs,
+				PageURL:    pageURL,
+				Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			if tt.refs != nil {
+				includes.References = tt.refs
+			}
+			if got := transformBody(t, body, includes); got != tt.want {
+				t.Errorf("Includes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
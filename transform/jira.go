@@ -0,0 +1,347 @@
+package transform
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// JiraIssue is the snapshot of a Jira issue written into a page.
+type JiraIssue struct {
+	Key        string
+	Summary    string
+	Status     string
+	Type       string
+	Priority   string
+	Assignee   string
+	Resolution string
+	Created    string
+	Updated    string
+}
+
+// JiraReference describes a jira macro as stored in the page: either a
+// single issue Key, or a JQL query shown as a table or as a count.
+// ServerURL is the Jira base URL that jiraissues macros store in their url
+// parameter.
+type JiraReference struct {
+	Key       string
+	JQL       string
+	Columns   []string
+	MaxIssues int
+	Count     bool
+	ServerURL string
+}
+
+// defaultJiraColumns are the columns of a JQL table macro that does not
+// list its own.
+var defaultJiraColumns = []string{"type", "key", "summary", "assignee", "priority", "status", "updated"}
+
+// jiraColumnTitles are the header titles of the supported table columns.
+var jiraColumnTitles = map[string]string{
+	"type":       "Type",
+	"issuetype":  "Type",
+	"key":        "Key",
+	"summary":    "Summary",
+	"status":     "Status",
+	"priority":   "Priority",
+	"assignee":   "Assignee",
+	"resolution": "Resolution",
+	"created":    "Created",
+	"updated":    "Updated",
+}
+
+// ParseJiraReferences lists the jira macros of a page in storage format, in
+// document order.
+func ParseJiraReferences(storage string) ([]JiraReference, error) {
+	macros, err := StorageMacros(storage, "jira", "jiraissues")
+	if err != nil {
+		return nil, err
+	}
+	var refs []JiraReference
+	for _, macro := range macros {
+		ref := JiraReference{
+			Key:       MacroParameter(macro, "key"),
+			JQL:       MacroParameter(macro, "jqlQuery"),
+			Count:     MacroParameter(macro, "count") == "true",
+			ServerURL: jiraServerURL(MacroParameter(macro, "url")),
+		}
+		for _, column := range strings.FieldsFunc(MacroParameter(macro, "columns"), func(r rune) bool { return r == ',' || r == ';' }) {
+			ref.Columns = append(ref.Columns, strings.ToLower(strings.TrimSpace(column)))
+		}
+		if maxIssues, err := strconv.Atoi(MacroParameter(macro, "maximumIssues")); err == nil {
+			ref.MaxIssues = maxIssues
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// jiraServerURL returns the Jira base URL of a link to an issue, a search or
+// a REST resource, or "" for any other link.
+func jiraServerURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	for _, marker := range []string{"/browse/", "/issues/", "/sr/", "/secure/", "/rest/"} {
+		if i := strings.Index(u.Path, marker); i >= 0 {
+			return u.Scheme + "://" + u.Host + u.Path[:i]
+		}
+	}
+	return ""
+}
+
+// Jira replaces rendered jira macros, which only work inside Confluence,
+// with static content: a single issue becomes a link to the issue followed
+// by its summary and status, a JQL table becomes a table snapshot of the
+// matching issues followed by a link to the live search, and a JQL count
+// becomes a link to the search. Issues are looked up with GetIssue and
+// Search; when a lookup fails, or no lookup is configured, the summary and
+// status Confluence rendered are kept for single issues, and JQL macros are
+// reduced to the link. Without a BaseURL, JQL macros link to the Jira server
+// named by the macro or its rendered links, or become a note quoting the
+// query when there is none.
+type Jira struct {
+	BaseURL    string
+	References []JiraReference
+	GetIssue   func(key string) (JiraIssue, error)
+	Search     func(jql string, maxIssues int) ([]JiraIssue, int, error)
+	// SnapshotDate is shown next to table snapshots.
+	SnapshotDate string
+	Logger       *slog.Logger
+}
+
+func (t Jira) Name() string {
+	return "jira"
+}
+
+func (t Jira) Transform(doc *html.Node) error {
+	isJira := or(ByMacro("jira"), ByMacro("jiraissues"), ByClass("confluence-jim-macro"))
+	macros := renderedMacros(doc, isJira)
+	// A rendered single issue names its key, which its reference must hold.
+	paired := pairMacros(macros, len(t.References), func(i int) bool {
+		key := Attr(macros[i], "data-jira-key")
+		return key == "" || key == t.References[i].Key
+	}, "jira", t.Logger)
+	for i, macro := range macros {
+		var ref JiraReference
+		if i < paired {
+			ref = t.References[i]
+		} else if key := Attr(macro, "data-jira-key"); key != "" {
+			ref = JiraReference{Key: key}
+		}
+		switch {
+		case ref.Key != "":
+			ReplaceWith(macro, t.issue(macro, ref)...)
+		case ref.JQL != "":
+			jira := t
+			if jira.BaseURL == "" {
+				jira.BaseURL = ref.ServerURL
+			}
+			if jira.BaseURL == "" {
+				jira.BaseURL = renderedJiraServerURL(macro)
+			}
+			if jira.BaseURL == "" {
+				t.Logger.Warn("Jira server of JQL macro unknown, replaced by the query", "jql", ref.JQL)
+				ReplaceWith(macro, jqlNote(ref)...)
+				continue
+			}
+			ReplaceWith(macro, jira.query(ref)...)
+		default:
+			t.Logger.Warn("Jira macro is kept as exported", "jql", ref.JQL)
+		}
+	}
+	return nil
+}
+
+// renderedJiraServerURL returns the Jira base URL of the first link of a
+// rendered jira macro that points at Jira.
+func renderedJiraServerURL(macro *html.Node) string {
+	for _, a := range FindAll(macro, ByTag("a")) {
+		if serverURL := jiraServerURL(Attr(a, "href")); serverURL != "" {
+			return serverURL
+		}
+	}
+	return ""
+}
+
+// jqlNote renders a JQL macro whose Jira server is unknown as the query.
+func jqlNote(ref JiraReference) []*html.Node {
+	note := []*html.Node{Text("Jira issues matching "), AppendChildren(Element("code"), Text(ref.JQL))}
+	if ref.Count {
+		return note
+	}
+	return []*html.Node{AppendChildren(Element("p"), note...)}
+}
+
+func (t Jira) browseURL(key string) string {
+	return strings.TrimSuffix(t.BaseURL, "/") + "/browse/" + key
+}
+
+func (t Jira) searchURL(jql string) string {
+	return strings.TrimSuffix(t.BaseURL, "/") + "/issues/?jql=" + url.QueryEscape(jql)
+}
+
+// issue renders "KEY: summary (status)" with the key linking to the issue
+// on BaseURL, the server the macro names or the link Confluence rendered.
+// Without any, the key is plain text.
+func (t Jira) issue(macro *html.Node, ref JiraReference) []*html.Node {
+	key := ref.Key
+	href := ""
+	switch {
+	case t.BaseURL != "":
+		href = t.browseURL(key)
+	case ref.ServerURL != "":
+		href = Jira{BaseURL: ref.ServerURL}.browseURL(key)
+	default:
+		for _, a := range FindAll(macro, ByTag("a")) {
+			if jiraServerURL(Attr(a, "href")) != "" {
+				href = Attr(a, "href")
+				break
+			}
+		}
+	}
+
+	var issue JiraIssue
+	fetched := false
+	if t.GetIssue != nil {
+		var err error
+		issue, err = t.GetIssue(key)
+		if err != nil {
+			t.Logger.Warn("Failed to get Jira issue, keeping the exported summary", "key", key, "error", err)
+		} else {
+			fetched = true
+		}
+	}
+	if !fetched {
+		if summary := Find(macro, ByClass("summary")); summary != nil {
+			issue.Summary = strings.TrimSpace(TextContent(summary))
+		}
+		if status := Find(macro, ByClass("aui-lozenge")); status != nil {
+			issue.Status = strings.TrimSpace(TextContent(status))
+		}
+	}
+
+	nodes := []*html.Node{Text(key)}
+	if href != "" {
+		nodes = []*html.Node{AppendChildren(Element("a", "href", href), Text(key))}
+	}
+	text := ""
+	if issue.Summary != "" {
+		text += ": " + issue.Summary
+	}
+	if issue.Status != "" {
+		text += " (" + issue.Status + ")"
+	}
+	if text != "" {
+		nodes = append(nodes, Text(text))
+	}
+	return nodes
+}
+
+// query renders a JQL macro as a table snapshot or count, followed by a link
+// to the live search.
+func (t Jira) query(ref JiraReference) []*html.Node {
+	link := AppendChildren(Element("a", "href", t.searchURL(ref.JQL)), Text("View in Jira"))
+	if t.Search == nil {
+		if ref.Count {
+			return []*html.Node{link}
+		}
+		return []*html.Node{AppendChildren(Element("p"), link)}
+	}
+
+	maxIssues := ref.MaxIssues
+	if maxIssues <= 0 {
+		maxIssues = 20
+	}
+	if ref.Count {
+		// Only the total is needed.
+		maxIssues = 0
+	}
+	issues, total, err := t.Search(ref.JQL, maxIssues)
+	if err != nil {
+		t.Logger.Warn("Failed to search Jira issues, linking to the search instead", "jql", ref.JQL, "error", err)
+		return []*html.Node{AppendChildren(Element("p"), link)}
+	}
+
+	if ref.Count {
+		countLink := AppendChildren(Element("a", "href", t.searchURL(ref.JQL)), Text(fmt.Sprintf("%d issues", total)))
+		return []*html.Node{countLink}
+	}
+
+	caption := fmt.Sprintf(" (snapshot of %d of %d issues taken on %s)", len(issues), total, t.SnapshotDate)
+	if len(issues) == total {
+		caption = fmt.Sprintf(" (snapshot of %d issues taken on %s)", total, t.SnapshotDate)
+	}
+	return []*html.Node{
+		t.issueTable(ref.Columns, issues),
+		AppendChildren(Element("p"), link, Text(caption)),
+	}
+}
+
+func (t Jira) issueTable(columns []string, issues []JiraIssue) *html.Node {
+	var supported []string
+	for _, column := range columns {
+		if _, ok := jiraColumnTitles[column]; ok {
+			supported = append(supported, column)
+		}
+	}
+	if len(supported) == 0 {
+		supported = defaultJiraColumns
+	}
+
+	headerRow := Element("tr")
+	for _, column := range supported {
+		headerRow.AppendChild(AppendChildren(Element("th"), Text(jiraColumnTitles[column])))
+	}
+	tbody := Element("tbody")
+	for _, issue := range issues {
+		row := Element("tr")
+		for _, column := range supported {
+			cell := Element("td")
+			if column == "key" {
+				cell.AppendChild(AppendChildren(Element("a", "href", t.browseURL(issue.Key)), Text(issue.Key)))
+			} else {
+				cell.AppendChild(Text(jiraIssueField(issue, column)))
+			}
+			row.AppendChild(cell)
+		}
+		tbody.AppendChild(row)
+	}
+	return AppendChildren(Element("table"), AppendChildren(Element("thead"), headerRow), tbody)
+}
+
+// jiraIssueField returns the value of a table column for issue. Timestamps
+// are shortened to their date.
+func jiraIssueField(issue JiraIssue, column string) string {
+	switch column {
+	case "type", "issuetype":
+		return issue.Type
+	case "summary":
+		return issue.Summary
+	case "status":
+		return issue.Status
+	case "priority":
+		return issue.Priority
+	case "assignee":
+		return issue.Assignee
+	case "resolution":
+		return issue.Resolution
+	case "created":
+		return shortDate(issue.Created)
+	case "updated":
+		return shortDate(issue.Updated)
+	}
+	return ""
+}
+
+func shortDate(timestamp string) string {
+	if len(timestamp) > 10 {
+		return timestamp[:10]
+	}
+	return timestamp
+}
@@ -0,0 +1,163 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestParseJiraReferences(t *testing.T) {
+	storage := `<p><ac:structured-macro ac:name="jira" ac:schema-version="1"><ac:parameter ac:name="server">Jira</ac:parameter>` +
+		`<ac:parameter ac:name="key">OPS-12</ac:parameter></ac:structured-macro></p>` +
+		`<ac:structured-macro ac:name="jira"><ac:parameter ac:name="columns">key,Summary, status</ac:parameter>` +
+		`<ac:parameter ac:name="maximumIssues">5</ac:parameter><ac:parameter ac:name="jqlQuery">project = OPS AND status != "Done"</ac:parameter></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="jira"><ac:parameter ac:name="count">true</ac:parameter><ac:parameter ac:name="jqlQuery">project = OPS</ac:parameter></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="jiraissues"><ac:parameter ac:name="url">https://jira.example.com/jira/sr/jira.issueviews:searchrequest-xml/temp/SearchRequest.xml?jqlQuery=project+%3D+OPS</ac:parameter>` +
+		`<ac:parameter ac:name="jqlQuery">project = OPS</ac:parameter></ac:structured-macro>`
+	got, err := ParseJiraReferences(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []JiraReference{
+		{Key: "OPS-12"},
+		{JQL: `project = OPS AND status != "Done"`, Columns: []string{"key", "summary", "status"}, MaxIssues: 5},
+		{JQL: "project = OPS", Count: true},
+		{JQL: "project = OPS", ServerURL: "https://jira.example.com/jira"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseJiraReferences() = %+v, want %+v", got, want)
+	}
+}
+
+func TestJira(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	issues := map[string]JiraIssue{
+		"OPS-1": {Key: "OPS-1", Summary: "Disk full", Status: "Open", Updated: "2026-01-02T10:00:00.000+0000"},
+		"OPS-2": {Key: "OPS-2", Summary: "Rotate keys", Status: "Done", Updated: "2026-02-03T10:00:00.000+0000"},
+	}
+	online := Jira{
+		BaseURL: "https://jira.example.com",
+		GetIssue: func(key string) (JiraIssue, error) {
+			issue, ok := issues[key]
+			if !ok {
+				return JiraIssue{}, errors.New("not found")
+			}
+			return issue, nil
+		},
+		Search: func(jql string, maxIssues int) ([]JiraIssue, int, error) {
+			return []JiraIssue{issues["OPS-1"], issues["OPS-2"]}[:min(maxIssues, 2)], 2, nil
+		},
+		SnapshotDate: "2026-10-19",
+		Logger:       logger,
+	}
+
+	singleIssue := func(key string) string {
+		return `<span class="confluence-jim-macro jira-issue" data-jira-key="` + key + `"><a href="https://jira.example.com/browse/` + key + `" class="jira-issue-key">` +
+			`<img class="icon" src="bug.svg"/>` + key + `</a> - <span class="summary">Old summary</span> <span class="aui-lozenge">Stale</span></span>`
+	}
+
+	tests := []struct {
+		name string
+		jira Jira
+		refs []JiraReference
+		body string
+		want string
+	}{
+		{
+			name: "single issue uses the fetched snapshot",
+			jira: online,
+			body: `<p>See ` + singleIssue("OPS-1") + `.</p>`,
+			want: `<p>See <a href="https://jira.example.com/browse/OPS-1">OPS-1</a>: Disk full (Open).</p>`,
+		},
+		{
+			name: "failed lookup keeps the rendered summary",
+			jira: online,
+			body: `<p>` + singleIssue("OPS-9") + `</p>`,
+			want: `<p><a href="https://jira.example.com/browse/OPS-9">OPS-9</a>: Old summary (Stale)</p>`,
+		},
+		{
+			name: "without Jira the rendered link is kept",
+			jira: Jira{Logger: logger},
+			body: `<p>` + singleIssue("OPS-1") + `</p>`,
+			want: `<p><a href="https://jira.example.com/browse/OPS-1">OPS-1</a>: Old summary (Stale)</p>`,
+		},
+		{
+			name: "JQL table becomes a snapshot",
+			jira: online,
+			refs: []JiraReference{{JQL: "project = OPS", Columns: []string{"key", "summary", "updated", "bogus"}}},
+			body: `<div class="jira-table conf-macro output-block" data-macro-name="jira"><span>Loading...</span></div>`,
+			want: `<table><thead><tr><th>Key</th><th>Summary</th><th>Updated</th></tr></thead><tbody>` +
+				`<tr><td><a href="https://jira.example.com/browse/OPS-1">OPS-1</a></td><td>Disk full</td><td>2026-01-02</td></tr>` +
+				`<tr><td><a href="https://jira.example.com/browse/OPS-2">OPS-2</a></td><td>Rotate keys</td><td>2026-02-03</td></tr></tbody></table>` +
+				`<p><a href="https://jira.example.com/issues/?jql=project+%3D+OPS">View in Jira</a> (snapshot of 2 issues taken on 2026-10-19)</p>`,
+		},
+		{
+			name: "JQL count becomes a link",
+			jira: online,
+			refs: []JiraReference{{JQL: "project = OPS", Count: true}},
+			body: `<p>Open: <span data-macro-name="jira">?</span></p>`,
+			want: `<p>Open: <a href="https://jira.example.com/issues/?jql=project+%3D+OPS">2 issues</a></p>`,
+		},
+		{
+			name: "JQL without Jira links to the server the macro names",
+			jira: Jira{Logger: logger},
+			refs: []JiraReference{{JQL: "project = OPS", ServerURL: "https://jira.example.com/jira"}},
+			body: `<div data-macro-name="jiraissues">table</div>`,
+			want: `<p><a href="https://jira.example.com/jira/issues/?jql=project+%3D+OPS">View in Jira</a></p>`,
+		},
+		{
+			name: "JQL without Jira links to the server of the rendered links",
+			jira: Jira{Logger: logger},
+			refs: []JiraReference{{JQL: "project = OPS", Count: true}},
+			body: `<p>Open: <span data-macro-name="jira"><a href="https://jira.example.com/issues/?jql=project+%3D+OPS">2 issues</a></span></p>`,
+			want: `<p>Open: <a href="https://jira.example.com/issues/?jql=project+%3D+OPS">View in Jira</a></p>`,
+		},
+		{
+			name: "single issue without a rendered link links to the server the macro names",
+			jira: Jira{Logger: logger},
+			refs: []JiraReference{{Key: "OPS-1", ServerURL: "https://jira.example.com/jira"}},
+			body: `<p>See <span class="conf-macro" data-macro-name="jira"></span></p>`,
+			want: `<p>See <a href="https://jira.example.com/jira/browse/OPS-1">OPS-1</a></p>`,
+		},
+		{
+			name: "single issue without any Jira server is plain text",
+			jira: Jira{Logger: logger},
+			refs: []JiraReference{{Key: "OPS-1"}},
+			body: `<p>See <span class="conf-macro" data-macro-name="jira"></span></p>`,
+			want: `<p>See OPS-1</p>`,
+		},
+		{
+			name: "pairing stops at an issue that is not its reference",
+			jira: online,
+			refs: []JiraReference{{Key: "OPS-1"}, {JQL: "project = OPS"}},
+			body: singleIssue("OPS-2") + `<div data-macro-name="jira">table</div>`,
+			want: `<a href="https://jira.example.com/browse/OPS-2">OPS-2</a>: Rotate keys (Done)<div data-macro-name="jira">table</div>`,
+		},
+		{
+			name: "macros of included pages are not paired",
+			jira: online,
+			refs: []JiraReference{{JQL: "project = OPS", Count: true}},
+			body: `<div data-macro-name="include"><span data-macro-name="jira">?</span></div><p><span data-macro-name="jira">?</span></p>`,
+			want: `<div data-macro-name="include"><span data-macro-name="jira">?</span></div>` +
+				`<p><a href="https://jira.example.com/issues/?jql=project+%3D+OPS">2 issues</a></p>`,
+		},
+		{
+			name: "JQL without any Jira server becomes the query",
+			jira: Jira{Logger: logger},
+			refs: []JiraReference{{JQL: "project = OPS"}},
+			body: `<div data-macro-name="jira">table</div>`,
+			want: `<p>Jira issues matching <code>project = OPS</code></p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.jira.References = tt.refs
+			if got := transformBody(t, tt.body, tt.jira); got != tt.want {
+				t.Errorf("Jira = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
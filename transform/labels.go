@@ -0,0 +1,43 @@
+package transform
+
+import (
+	"golang.org/x/net/html"
+)
+
+// LabelsHeader lists the labels of a page below its title, since Outline has
+// no labels. Each label links to the document indexing the pages with it.
+type LabelsHeader struct {
+	Labels []string
+	// URL returns the link target of a label, or "" to leave it unlinked.
+	URL func(label string) string
+}
+
+func (t LabelsHeader) Name() string {
+	return "labels header"
+}
+
+func (t LabelsHeader) Transform(doc *html.Node) error {
+	if len(t.Labels) == 0 {
+		return nil
+	}
+	em := AppendChildren(Element("em"), Text("Labels: "))
+	for i, label := range t.Labels {
+		if i > 0 {
+			em.AppendChild(Text(", "))
+		}
+		if url := t.URL(label); url != "" {
+			em.AppendChild(AppendChildren(Element("a", "href", url), Text(label)))
+		} else {
+			em.AppendChild(Text(label))
+		}
+	}
+	header := AppendChildren(Element("p"), em)
+
+	body := Body(doc)
+	if title := Find(body, ByTag("h1")); title != nil && title.Parent == body {
+		body.InsertBefore(header, title.NextSibling)
+		return nil
+	}
+	body.InsertBefore(header, body.FirstChild)
+	return nil
+}
@@ -0,0 +1,44 @@
+package transform
+
+import "testing"
+
+func TestLabelsHeader(t *testing.T) {
+	labelURL := func(label string) string {
+		if label == "unindexed" {
+			return ""
+		}
+		return "/label/KEY/" + label
+	}
+	tests := []struct {
+		name   string
+		header LabelsHeader
+		body   string
+		want   string
+	}{
+		{
+			name:   "below the title",
+			header: LabelsHeader{Labels: []string{"howto", "unindexed"}, URL: labelURL},
+			body:   `<h1>Setup</h1><p>Body</p>`,
+			want:   `<h1>Setup</h1><p><em>Labels: <a href="/label/KEY/howto">howto</a>, unindexed</em></p><p>Body</p>`,
+		},
+		{
+			name:   "without title",
+			header: LabelsHeader{Labels: []string{"howto"}, URL: labelURL},
+			body:   `<p>Body</p>`,
+			want:   `<p><em>Labels: <a href="/label/KEY/howto">howto</a></em></p><p>Body</p>`,
+		},
+		{
+			name:   "without labels",
+			header: LabelsHeader{URL: labelURL},
+			body:   `<h1>Setup</h1><p>Body</p>`,
+			want:   `<h1>Setup</h1><p>Body</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, tt.header); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
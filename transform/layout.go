@@ -0,0 +1,126 @@
+package transform
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// layoutBlockTags are the elements whose presence in a table cell shows the
+// table lays out content rather than holding tabular data.
+var layoutBlockTags = map[string]bool{
+	"p": true, "div": true, "ul": true, "ol": true, "table": true, "pre": true, "img": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Layout linearizes page layouts. The columns of page layout sections and of
+// section/column macros are placed one after another in reading order, and
+// tables used for layout rather than data are replaced by the content of
+// their cells. With Separators a horizontal rule is put between columns.
+type Layout struct {
+	Separators bool
+}
+
+func (t Layout) Name() string {
+	return "layout"
+}
+
+func (t Layout) Transform(doc *html.Node) error {
+	// Page layouts: contentLayout2 > columnLayout > cell > innerCell.
+	for _, section := range FindAll(doc, or(ByClass("columnLayout"), ByClass("sectionMacroRow"))) {
+		var columns []*html.Node
+		for c := section.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (HasClass(c, "cell") || HasClass(c, "columnMacro")) {
+				columns = append(columns, c)
+			}
+		}
+		t.linearize(section, columns)
+	}
+	for _, wrapper := range FindAll(doc, or(ByClass("contentLayout2"), ByClass("contentLayout"), ByClass("sectionColumnWrapper"), ByClass("sectionMacro"))) {
+		if !IsElement(wrapper, "table") {
+			Unwrap(wrapper)
+		}
+	}
+
+	for _, table := range FindAll(doc, ByTag("table")) {
+		if table.Parent == nil || !isLayoutTable(table) {
+			continue
+		}
+		t.linearize(table, FindAll(table, func(n *html.Node) bool {
+			return IsElement(n, "td") && closestTable(n) == table
+		}))
+	}
+	return nil
+}
+
+// linearize replaces container with the content of its columns, in order,
+// dropping empty columns.
+func (t Layout) linearize(container *html.Node, columns []*html.Node) {
+	var content []*html.Node
+	for _, column := range columns {
+		if strings.TrimSpace(TextContent(column)) == "" && Find(column, ByTag("img")) == nil {
+			continue
+		}
+		if t.Separators && len(content) > 0 {
+			content = append(content, Element("hr"))
+		}
+		inner := column
+		if innerCell := Find(column, ByClass("innerCell")); innerCell != nil {
+			inner = innerCell
+		}
+		for c := inner.FirstChild; c != nil; c = c.NextSibling {
+			content = append(content, c)
+		}
+	}
+	ReplaceWith(container, content...)
+}
+
+// isLayoutTable reports whether table arranges content rather than holding
+// data: a table rendered by the section macro, one with a presentation role,
+// or a table with a single row and no header whose cells hold block content.
+// A row of single paragraphs is taken as data, as Confluence wraps plain
+// cell values in paragraphs.
+func isLayoutTable(table *html.Node) bool {
+	role := Attr(table, "role")
+	if HasClass(table, "sectionMacro") || role == "presentation" || role == "none" {
+		return true
+	}
+	ownRows := FindAll(table, func(n *html.Node) bool {
+		return IsElement(n, "tr") && closestTable(n) == table
+	})
+	if len(ownRows) != 1 {
+		return false
+	}
+	hasLayout := false
+	for _, cell := range FindAll(ownRows[0], func(n *html.Node) bool { return IsElement(n, "td") || IsElement(n, "th") }) {
+		if cell.Parent != ownRows[0] {
+			continue
+		}
+		if IsElement(cell, "th") {
+			return false
+		}
+		var blocks []*html.Node
+		for c := cell.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && layoutBlockTags[c.Data] {
+				blocks = append(blocks, c)
+			} else if c.Type == html.ElementNode || strings.TrimSpace(c.Data) != "" {
+				// Inline content directly in the cell reads as a value.
+				return false
+			}
+		}
+		if len(blocks) > 1 || len(blocks) == 1 && !IsElement(blocks[0], "p") {
+			hasLayout = true
+		}
+	}
+	return hasLayout
+}
+
+// closestTable returns the nearest <table> ancestor of n.
+func closestTable(n *html.Node) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if IsElement(p, "table") {
+			return p
+		}
+	}
+	return nil
+}
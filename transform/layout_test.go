@@ -0,0 +1,97 @@
+package transform
+
+import "testing"
+
+func TestLayout(t *testing.T) {
+	twoColumns := `<div class="contentLayout2"><div class="columnLayout two-equal" data-layout="two-equal">` +
+		`<div class="cell normal" data-type="normal"><div class="innerCell"><p>Left</p></div></div>` +
+		`<div class="cell normal" data-type="normal"><div class="innerCell"><p>Right</p></div></div></div>` +
+		`<div class="columnLayout single" data-layout="single"><div class="cell normal"><div class="innerCell"><p>Below</p><p> </p></div></div></div>` +
+		`<div class="columnLayout two-equal"><div class="cell"><div class="innerCell"><p> </p></div></div><div class="cell"><div class="innerCell"><p>Only</p></div></div></div></div>`
+
+	tests := []struct {
+		name   string
+		layout Layout
+		body   string
+		want   string
+	}{
+		{
+			name: "columns in reading order",
+			body: twoColumns,
+			want: `<p>Left</p><p>Right</p><p>Below</p><p> </p><p>Only</p>`,
+		},
+		{
+			name:   "columns with separators",
+			layout: Layout{Separators: true},
+			body:   twoColumns,
+			want:   `<p>Left</p><hr/><p>Right</p><p>Below</p><p> </p><p>Only</p>`,
+		},
+		{
+			name: "section and column macros",
+			body: `<div class="sectionColumnWrapper"><div class="sectionMacro"><div class="sectionMacroRow">` +
+				`<div class="columnMacro" style="width:30%"><p>Nav</p></div><div class="columnMacro"><h2>Main</h2></div></div></div></div>`,
+			want: `<p>Nav</p><h2>Main</h2>`,
+		},
+		{
+			name: "section macro table",
+			body: `<table class="sectionMacro" border="0" cellpadding="5" cellspacing="0" width="100%"><tbody><tr>` +
+				`<td class="columnMacro"><p>Nav</p></td><td class="columnMacro"><h2>Main</h2></td></tr></tbody></table>`,
+			want: `<p>Nav</p><h2>Main</h2>`,
+		},
+		{
+			name: "presentation table",
+			body: `<div class="table-wrap"><table role="presentation"><tbody><tr>` +
+				`<td><p>Intro</p><ul><li>a</li></ul></td><td><img src="x.png"/></td></tr></tbody></table></div>`,
+			want: `<div class="table-wrap"><p>Intro</p><ul><li>a</li></ul><img src="x.png"/></div>`,
+		},
+		{
+			name: "single row of block content",
+			body: `<div class="table-wrap"><table class="confluenceTable"><tbody><tr>` +
+				`<td class="confluenceTd"><p>Intro</p><ul><li>a</li></ul></td><td class="confluenceTd"><img src="x.png"/></td></tr></tbody></table></div>`,
+			want: `<div class="table-wrap"><p>Intro</p><ul><li>a</li></ul><img src="x.png"/></div>`,
+		},
+		{
+			name: "single cell of block content",
+			body: `<table><tbody><tr><td><h2>Boxed</h2><p>text</p></td></tr></tbody></table>`,
+			want: `<h2>Boxed</h2><p>text</p>`,
+		},
+		{
+			name: "single cell of plain text is kept",
+			body: `<table><tbody><tr><td>Boxed text</td></tr></tbody></table>`,
+			want: `<table><tbody><tr><td>Boxed text</td></tr></tbody></table>`,
+		},
+		{
+			name: "single row of paragraphs is kept",
+			body: `<table><tbody><tr><td><p>1</p></td><td><p>2</p></td></tr></tbody></table>`,
+			want: `<table><tbody><tr><td><p>1</p></td><td><p>2</p></td></tr></tbody></table>`,
+		},
+		{
+			name: "single header row is kept",
+			body: `<table><tbody><tr><th><p>Name</p></th><td><ul><li>a</li></ul></td></tr></tbody></table>`,
+			want: `<table><tbody><tr><th><p>Name</p></th><td><ul><li>a</li></ul></td></tr></tbody></table>`,
+		},
+		{
+			name: "data table is kept",
+			body: `<table><tbody><tr><th>Name</th><th>Role</th></tr><tr><td>Ann</td><td>Dev</td></tr></tbody></table>`,
+			want: `<table><tbody><tr><th>Name</th><th>Role</th></tr><tr><td>Ann</td><td>Dev</td></tr></tbody></table>`,
+		},
+		{
+			name: "single row of plain values is kept",
+			body: `<table><tbody><tr><td>1</td><td>2</td></tr></tbody></table>`,
+			want: `<table><tbody><tr><td>1</td><td>2</td></tr></tbody></table>`,
+		},
+		{
+			name: "data table inside a layout table is kept",
+			body: `<table role="presentation"><tbody><tr><td><table><tbody><tr><th>K</th></tr><tr><td>V</td></tr></tbody></table></td></tr></tbody></table>`,
+			want: `<table><tbody><tr><th>K</th></tr><tr><td>V</td></tr></tbody></table>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, tt.layout); got != tt.want {
+				t.Errorf("Layout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
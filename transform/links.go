@@ -0,0 +1,39 @@
+package transform
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Links points <a href> targets at migrated documents. Lookup receives the
+// link path relative to the Confluence host (e.g. /display/KEY/Title) and
+// returns the new relative URL. Relative links stay relative; absolute links
+// to the Confluence host become absolute links to NewHostname.
+type Links struct {
+	ConfluenceHostname string
+	NewHostname        string
+	Lookup             func(confluencePath string) (string, bool)
+}
+
+func (t Links) Name() string {
+	return "links"
+}
+
+func (t Links) Transform(doc *html.Node) error {
+	confluenceHostname := strings.TrimSuffix(t.ConfluenceHostname, "/")
+	for _, a := range FindAll(doc, ByTag("a")) {
+		href := Attr(a, "href")
+		prefix := ""
+		if confluenceHostname != "" && strings.HasPrefix(href, confluenceHostname+"/") {
+			href = strings.TrimPrefix(href, confluenceHostname)
+			prefix = t.NewHostname
+		}
+		newUrl, ok := t.Lookup(href)
+		if !ok {
+			continue
+		}
+		SetAttr(a, "href", prefix+newUrl)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package transform
+
+import "testing"
+
+func TestLinks(t *testing.T) {
+	urlMap := map[string]string{
+		"/display/ENG/Home":                "/doc/home-abc",
+		"/pages/viewpage.action?pageId=42": "/doc/setup-def",
+	}
+	links := Links{
+		ConfluenceHostname: "https://confluence.example.com",
+		NewHostname:        "https://outline.example.com",
+		Lookup: func(confluencePath string) (string, bool) {
+			newUrl, ok := urlMap[confluencePath]
+			return newUrl, ok
+		},
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "relative link rewrites to relative",
+			body: `<a href="/display/ENG/Home">Home</a>`,
+			want: `<a href="/doc/home-abc">Home</a>`,
+		},
+		{
+			name: "absolute link rewrites to absolute",
+			body: `<a class="x" href='https://confluence.example.com/pages/viewpage.action?pageId=42'>Setup</a>`,
+			want: `<a class="x" href="https://outline.example.com/doc/setup-def">Setup</a>`,
+		},
+		{
+			name: "unknown link is left alone",
+			body: `<a href="/display/ENG/Missing">Missing</a>`,
+			want: `<a href="/display/ENG/Missing">Missing</a>`,
+		},
+		{
+			name: "image src is left alone",
+			body: `<img src="/display/ENG/Home"/>`,
+			want: `<img src="/display/ENG/Home"/>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, links); got != tt.want {
+				t.Errorf("Links = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
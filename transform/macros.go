@@ -0,0 +1,240 @@
+package transform
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageLink is an entry of a generated list of pages.
+type PageLink struct {
+	Title    string
+	URL      string
+	Children []PageLink
+}
+
+// Macros converts Confluence macros whose export is empty or only works
+// inside Confluence:
+//
+//   - expand macros become <details> blocks with the expand title as summary,
+//     which Outline shows expanded as it has no collapsible blocks,
+//   - table of contents macros are removed, or regenerated as a list of links
+//     to the headings of the page when RegenerateTOC is set,
+//   - children and pagetree macros become link lists built from PageTree,
+//   - recently-updated macros are removed.
+type Macros struct {
+	// PageID is the id of the page being transformed, whose children a
+	// children macro lists.
+	PageID        string
+	RegenerateTOC bool
+	// PageTree returns the pages below rootPageId, or the root pages of the
+	// space when rootPageId is "". A nil PageTree removes children and
+	// pagetree macros.
+	PageTree func(rootPageId string) ([]PageLink, error)
+	// Report, if set, is passed every expand macro, whose content is no
+	// longer collapsed.
+	Report func(issue string)
+	Logger *slog.Logger
+}
+
+func (t Macros) Name() string {
+	return "macros"
+}
+
+// ByMacro matches elements rendered by the Confluence macro with the given
+// name.
+func ByMacro(name string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		return n.Type == html.ElementNode && Attr(n, "data-macro-name") == name
+	}
+}
+
+func (t Macros) Transform(doc *html.Node) error {
+	for _, expand := range FindAll(doc, or(ByMacro("expand"), ByClass("expand-container"))) {
+		details := detailsBlock(expand)
+		ReplaceWith(expand, details)
+		if t.Report != nil {
+			t.Report(fmt.Sprintf("Expand macro %q shown expanded, as Outline has no collapsible blocks", TextContent(Find(details, ByTag("summary")))))
+		}
+	}
+
+	for _, toc := range FindAll(doc, or(ByMacro("toc"), ByClass("toc-macro"))) {
+		if toc.Parent == nil {
+			continue
+		}
+		if !t.RegenerateTOC {
+			Remove(toc)
+			continue
+		}
+		replaceWithPageList(toc, tableOfContents(doc, toc))
+	}
+
+	for _, children := range FindAll(doc, ByMacro("children")) {
+		var pages []PageLink
+		for _, page := range t.pageTree(t.PageID) {
+			pages = append(pages, PageLink{Title: page.Title, URL: page.URL})
+		}
+		replaceWithPageList(children, pages)
+	}
+
+	for _, pagetree := range FindAll(doc, or(ByMacro("pagetree"), ByClass("plugin_pagetree"))) {
+		if pagetree.Parent == nil {
+			continue
+		}
+		rootPageId := ""
+		if input := Find(pagetree, func(n *html.Node) bool {
+			return IsElement(n, "input") && Attr(n, "name") == "rootPageId"
+		}); input != nil {
+			rootPageId = Attr(input, "value")
+		}
+		replaceWithPageList(pagetree, t.pageTree(rootPageId))
+	}
+
+	for _, recentlyUpdated := range FindAll(doc, ByMacro("recently-updated")) {
+		Remove(recentlyUpdated)
+	}
+	return nil
+}
+
+// pageTree calls PageTree, logging failures, which leave the macro empty.
+func (t Macros) pageTree(rootPageId string) []PageLink {
+	if t.PageTree == nil {
+		return nil
+	}
+	pages, err := t.PageTree(rootPageId)
+	if err != nil {
+		t.Logger.Warn("Failed to list pages for macro", "rootPageId", rootPageId, "error", err)
+		return nil
+	}
+	return pages
+}
+
+// or matches nodes matched by any of matches.
+func or(matches ...func(*html.Node) bool) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		for _, match := range matches {
+			if match(n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// detailsBlock builds a <details> block from an expand macro.
+func detailsBlock(expand *html.Node) *html.Node {
+	title := "Click here to expand..."
+	if control := Find(expand, ByClass("expand-control-text")); control != nil {
+		if text := strings.TrimSpace(TextContent(control)); text != "" {
+			title = text
+		}
+	}
+	details := AppendChildren(Element("details"), AppendChildren(Element("summary"), Text(title)))
+	if content := Find(expand, ByClass("expand-content")); content != nil {
+		MoveChildren(details, content)
+	}
+	return details
+}
+
+// replaceWithPageList replaces macro with a list of links to pages, or
+// removes it when there are none.
+func replaceWithPageList(macro *html.Node, pages []PageLink) {
+	if len(pages) == 0 {
+		Remove(macro)
+		return
+	}
+	ReplaceWith(macro, pageList(pages))
+}
+
+// pageList renders pages as nested <ul> lists of links.
+func pageList(pages []PageLink) *html.Node {
+	ul := Element("ul")
+	for _, page := range pages {
+		li := AppendChildren(Element("li"), AppendChildren(Element("a", "href", page.URL), Text(page.Title)))
+		if len(page.Children) > 0 {
+			li.AppendChild(pageList(page.Children))
+		}
+		ul.AppendChild(li)
+	}
+	return ul
+}
+
+var headingLevelRegex = regexp.MustCompile(`^h([1-6])$`)
+
+// tableOfContents lists the headings of doc within the levels configured on
+// the toc macro as nested links to Outline heading anchors. The title heading
+// ExportHTML puts first in the body is skipped.
+func tableOfContents(doc *html.Node, toc *html.Node) []PageLink {
+	minLevel, maxLevel := 1, 6
+	if level, err := strconv.Atoi(Attr(toc, "data-minlevel")); err == nil {
+		minLevel = level
+	}
+	if level, err := strconv.Atoi(Attr(toc, "data-maxlevel")); err == nil {
+		maxLevel = level
+	}
+
+	var titleHeading *html.Node
+	body := Body(doc)
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			if IsElement(c, "h1") {
+				titleHeading = c
+			}
+			break
+		}
+	}
+
+	type heading struct {
+		level int
+		link  PageLink
+	}
+	var headings []heading
+	anchors := make(map[string]int)
+	for _, n := range FindAll(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && headingLevelRegex.MatchString(n.Data)
+	}) {
+		if n == titleHeading {
+			continue
+		}
+		level, _ := strconv.Atoi(n.Data[1:])
+		text := strings.TrimSpace(TextContent(n))
+		anchor := headingAnchor(text, anchors[headingAnchor(text, 0)])
+		anchors[headingAnchor(text, 0)]++
+		if level < minLevel || level > maxLevel || text == "" {
+			continue
+		}
+		headings = append(headings, heading{level: level, link: PageLink{Title: text, URL: "#" + anchor}})
+	}
+
+	// Nest every heading below the closest preceding heading of a higher
+	// level.
+	var nest func(start, level int) ([]PageLink, int)
+	nest = func(i, level int) ([]PageLink, int) {
+		var links []PageLink
+		for i < len(headings) && headings[i].level >= level {
+			link := headings[i].link
+			link.Children, i = nest(i+1, headings[i].level+1)
+			links = append(links, link)
+		}
+		return links, i
+	}
+	links, _ := nest(0, 0)
+	return links
+}
+
+var headingAnchorRemoveRegex = regexp.MustCompile("[!\"#$%&'.()*+,/:;<=>?@\\[\\]\\\\^_`{|}~]")
+
+// headingAnchor returns the anchor Outline gives the index-th heading (counted
+// from 0) with the given text.
+func headingAnchor(text string, index int) string {
+	anchor := headingAnchorRemoveRegex.ReplaceAllString(strings.ToLower(text), "")
+	anchor = "h-" + strings.Join(strings.Fields(anchor), "-")
+	if index > 0 {
+		anchor += "-" + strconv.Itoa(index)
+	}
+	return anchor
+}
@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestMacros(t *testing.T) {
+	tree := map[string][]PageLink{
+		"": {
+			{Title: "Home", URL: "/pages/viewpage.action?pageId=1", Children: []PageLink{
+				{Title: "Setup", URL: "/pages/viewpage.action?pageId=2", Children: []PageLink{
+					{Title: "Linux", URL: "/pages/viewpage.action?pageId=3"},
+				}},
+			}},
+		},
+		"1": {
+			{Title: "Setup", URL: "/pages/viewpage.action?pageId=2", Children: []PageLink{
+				{Title: "Linux", URL: "/pages/viewpage.action?pageId=3"},
+			}},
+		},
+	}
+	macros := Macros{
+		PageID: "1",
+		PageTree: func(rootPageId string) ([]PageLink, error) {
+			if rootPageId == "broken" {
+				return nil, errors.New("boom")
+			}
+			return tree[rootPageId], nil
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "expand becomes details",
+			body: `<div id="expander-1" class="expand-container conf-macro output-block" data-macro-name="expand">` +
+				`<div class="expand-control"><span class="expand-control-icon icon"> </span><span class="expand-control-text">Show logs</span></div>` +
+				`<div class="expand-content expand-hidden"><p>Line <b>1</b></p></div></div>`,
+			want: `<details><summary>Show logs</summary><p>Line <b>1</b></p></details>`,
+		},
+		{
+			name: "expand without title",
+			body: `<div class="expand-container" data-macro-name="expand"><div class="expand-content"><p>x</p></div></div>`,
+			want: `<details><summary>Click here to expand...</summary><p>x</p></details>`,
+		},
+		{
+			name: "toc is dropped",
+			body: `<div class="toc-macro client-side-toc-macro" data-macro-name="toc" data-headerelements="H1,H2"></div><h2>A</h2>`,
+			want: `<h2>A</h2>`,
+		},
+		{
+			name: "children lists direct children",
+			body: `<div class="conf-macro output-block" data-macro-name="children"><ul class="childpages-macro"><li>stale</li></ul></div>`,
+			want: `<ul><li><a href="/pages/viewpage.action?pageId=2">Setup</a></li></ul>`,
+		},
+		{
+			name: "pagetree without root lists the space",
+			body: `<div class="plugin_pagetree conf-macro output-block" data-macro-name="pagetree"><ul class="plugin_pagetree_children_list"></ul></div>`,
+			want: `<ul><li><a href="/pages/viewpage.action?pageId=1">Home</a><ul><li><a href="/pages/viewpage.action?pageId=2">Setup</a>` +
+				`<ul><li><a href="/pages/viewpage.action?pageId=3">Linux</a></li></ul></li></ul></li></ul>`,
+		},
+		{
+			name: "pagetree with root page",
+			body: `<div class="plugin_pagetree" data-macro-name="pagetree"><fieldset class="hidden"><input type="hidden" name="rootPageId" value="1"/></fieldset></div>`,
+			want: `<ul><li><a href="/pages/viewpage.action?pageId=2">Setup</a><ul><li><a href="/pages/viewpage.action?pageId=3">Linux</a></li></ul></li></ul>`,
+		},
+		{
+			name: "pagetree that fails to load is removed",
+			body: `<p>a</p><div class="plugin_pagetree" data-macro-name="pagetree"><input type="hidden" name="rootPageId" value="broken"/></div>`,
+			want: `<p>a</p>`,
+		},
+		{
+			name: "recently updated is removed",
+			body: `<div class="recently-updated" data-macro-name="recently-updated"><ul><li>junk</li></ul></div><p>b</p>`,
+			want: `<p>b</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, macros); got != tt.want {
+				t.Errorf("Macros = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacrosRegenerateTOC(t *testing.T) {
+	body := `<h1>Page title</h1><div class="toc-macro" data-macro-name="toc" data-maxlevel="3"></div>` +
+		`<h2>Install</h2><h3>On Linux</h3><h4>Too deep</h4><h2>Usage: CLI</h2><h3>On Linux</h3>`
+	want := `<h1>Page title</h1><ul><li><a href="#h-install">Install</a><ul><li><a href="#h-on-linux">On Linux</a></li></ul></li>` +
+		`<li><a href="#h-usage-cli">Usage: CLI</a><ul><li><a href="#h-on-linux-1">On Linux</a></li></ul></li></ul>` +
+		`<h2>Install</h2><h3>On Linux</h3><h4>Too deep</h4><h2>Usage: CLI</h2><h3>On Linux</h3>`
+	if got := transformBody(t, body, Macros{RegenerateTOC: true}); got != want {
+		t.Errorf("Macros = %q, want %q", got, want)
+	}
+}
+
+func TestMacrosReportsExpands(t *testing.T) {
+	var issues []string
+	macros := Macros{Report: func(issue string) { issues = append(issues, issue) }}
+	body := `<div class="expand-container" data-macro-name="expand"><div class="expand-control"><span class="expand-control-text">Show logs</span></div>` +
+		`<div class="expand-content"><p>x</p></div></div>`
+	transformBody(t, body, macros)
+	want := []string{`Expand macro "Show logs" shown expanded, as Outline has no collapsible blocks`}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("reported %q, want %q", issues, want)
+	}
+}
@@ -0,0 +1,93 @@
+package transform
+
+import (
+	"log/slog"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// mathMacros maps the names of math macros to whether they render a block
+// (display) formula.
+var mathMacros = map[string]bool{
+	"mathblock":            true,
+	"mathinline":           false,
+	"mathjax-block-macro":  true,
+	"mathjax-inline-macro": false,
+	"latex":                true,
+	"latex-formatting":     true,
+	"latex-inline":         false,
+}
+
+// MathReference is a math macro as stored in the page.
+type MathReference struct {
+	Macro   string
+	TeX     string
+	Display bool
+}
+
+// ParseMathReferences lists the math macros of a page in storage format, in
+// document order. The TeX source is the macro body, or its body parameter
+// for inline macros that keep the formula there.
+func ParseMathReferences(storage string) ([]MathReference, error) {
+	var names []string
+	for name := range mathMacros {
+		names = append(names, name)
+	}
+	macros, err := StorageMacros(storage, names...)
+	if err != nil {
+		return nil, err
+	}
+	var refs []MathReference
+	for _, macro := range macros {
+		tex := MacroBody(macro)
+		if tex == "" {
+			tex = MacroParameter(macro, "body")
+		}
+		name := Attr(macro, "ac:name")
+		refs = append(refs, MathReference{
+			Macro:   name,
+			TeX:     strings.TrimSpace(tex),
+			Display: mathMacros[name],
+		})
+	}
+	return refs, nil
+}
+
+// Math replaces rendered math macros, which export as images or raw markup,
+// with Outline math nodes (<math-inline> and <math-display>) holding the
+// TeX source, so formulas stay editable. References are paired with the
+// rendered macros by position; when the counts or macro names differ, the
+// unpaired macros are kept as exported.
+type Math struct {
+	References []MathReference
+	Logger     *slog.Logger
+}
+
+func (t Math) Name() string {
+	return "math"
+}
+
+func (t Math) Transform(doc *html.Node) error {
+	isMath := func(n *html.Node) bool {
+		_, ok := mathMacros[Attr(n, "data-macro-name")]
+		return n.Type == html.ElementNode && ok
+	}
+	macros := renderedMacros(doc, isMath)
+	matches := func(i int) bool {
+		return Attr(macros[i], "data-macro-name") == t.References[i].Macro
+	}
+	paired := pairMacros(macros, len(t.References), matches, "math", t.Logger)
+	for i, macro := range macros[:paired] {
+		ref := t.References[i]
+		if ref.TeX == "" {
+			continue
+		}
+		tag := "math-inline"
+		if ref.Display {
+			tag = "math-display"
+		}
+		ReplaceWith(macro, AppendChildren(Element(tag, "class", "math-node"), Text(ref.TeX)))
+	}
+	return nil
+}
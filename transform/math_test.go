@@ -0,0 +1,76 @@
+package transform
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestParseMathReferences(t *testing.T) {
+	storage := `<p>Energy <ac:structured-macro ac:name="mathinline"><ac:parameter ac:name="body">E = mc^2</ac:parameter></ac:structured-macro></p>` +
+		`<ac:structured-macro ac:name="mathblock"><ac:plain-text-body><![CDATA[
+\sum_{i=1}^{n} x_i < 1
+]]></ac:plain-text-body></ac:structured-macro>`
+	got, err := ParseMathReferences(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MathReference{
+		{Macro: "mathinline", TeX: "E = mc^2"},
+		{Macro: "mathblock", TeX: `\sum_{i=1}^{n} x_i < 1`, Display: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMathReferences() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMath(t *testing.T) {
+	refs := []MathReference{
+		{Macro: "mathinline", TeX: "E = mc^2"},
+		{Macro: "mathblock", TeX: `\sum_{i=1}^{n} x_i < 1`, Display: true},
+	}
+	inline := `<img class="editor-inline-macro" data-macro-name="mathinline" src="/plugins/math/render.png"/>`
+	block := `<div class="conf-macro output-block" data-macro-name="mathblock"><img src="/plugins/math/block.png"/></div>`
+	tests := []struct {
+		name string
+		refs []MathReference
+		body string
+		want string
+	}{
+		{
+			name: "paired by position",
+			refs: refs,
+			body: `<p>Energy ` + inline + `</p>` + block,
+			want: `<p>Energy <math-inline class="math-node">E = mc^2</math-inline></p>` +
+				`<math-display class="math-node">\sum_{i=1}^{n} x_i &lt; 1</math-display>`,
+		},
+		{
+			name: "count mismatch keeps all",
+			refs: refs[:1],
+			body: `<p>` + inline + `</p>` + block,
+			want: `<p>` + inline + `</p>` + block,
+		},
+		{
+			name: "name mismatch keeps the rest",
+			refs: []MathReference{refs[0], {Macro: "mathinline", TeX: "x"}},
+			body: `<p>` + inline + `</p>` + block,
+			want: `<p><math-inline class="math-node">E = mc^2</math-inline></p>` + block,
+		},
+		{
+			name: "included macros are skipped",
+			refs: refs[1:],
+			body: `<div data-included-from="Formulas"><p>` + inline + `</p></div>` + block,
+			want: `<div data-included-from="Formulas"><p>` + inline + `</p></div>` +
+				`<math-display class="math-node">\sum_{i=1}^{n} x_i &lt; 1</math-display>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			math := Math{References: tt.refs, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+			if got := transformBody(t, tt.body, math); got != tt.want {
+				t.Errorf("Math = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
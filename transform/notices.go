@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// informationMacroStyles maps the class Confluence puts on info, note,
+// warning and tip macros to the Outline notice style closest in colour.
+var informationMacroStyles = map[string]string{
+	"confluence-information-macro-information": "info",
+	"confluence-information-macro-note":        "warning",
+	"confluence-information-macro-warning":     "warning",
+	"confluence-information-macro-tip":         "tip",
+}
+
+// panelTypeStyles maps the panel type of Cloud panels to Outline notice
+// styles.
+var panelTypeStyles = map[string]string{
+	"info":    "info",
+	"note":    "tip",
+	"tip":     "tip",
+	"success": "success",
+	"warning": "warning",
+	"error":   "warning",
+}
+
+// Notices replaces Confluence info, note, warning and tip macros and panel
+// macros with Outline notice blocks
+// (<div class="notice-block STYLE"><div class="content">...</div></div>).
+// A macro title becomes a bold first paragraph of the notice.
+type Notices struct{}
+
+func (t Notices) Name() string {
+	return "notices"
+}
+
+func (t Notices) Transform(doc *html.Node) error {
+	for _, macro := range FindAll(doc, ByClass("confluence-information-macro")) {
+		style := "info"
+		for class, s := range informationMacroStyles {
+			if HasClass(macro, class) {
+				style = s
+				break
+			}
+		}
+		title := ""
+		if titleNode := Find(macro, ByClass("title")); titleNode != nil {
+			title = TextContent(titleNode)
+			Remove(titleNode)
+		}
+		for _, icon := range FindAll(macro, ByClass("confluence-information-macro-icon")) {
+			Remove(icon)
+		}
+		body := Find(macro, ByClass("confluence-information-macro-body"))
+		if body == nil {
+			body = macro
+		}
+		ReplaceWith(macro, noticeBlock(style, title, body))
+	}
+
+	isPanel := func(n *html.Node) bool {
+		// Code panels share the panel class; CodeBlocks handles those.
+		return n.Type == html.ElementNode && HasClass(n, "panel") && !HasClass(n, "code")
+	}
+	for _, panel := range FindAll(doc, isPanel) {
+		style := "info"
+		if s, ok := panelTypeStyles[Attr(panel, "data-panel-type")]; ok {
+			style = s
+		}
+		title := ""
+		if header := Find(panel, ByClass("panelHeader")); header != nil {
+			title = TextContent(header)
+			Remove(header)
+		}
+		body := Find(panel, ByClass("panelContent"))
+		if body == nil {
+			body = panel
+		}
+		ReplaceWith(panel, noticeBlock(style, title, body))
+	}
+	return nil
+}
+
+// noticeBlock builds an Outline notice of the given style and moves the
+// children of body into it.
+func noticeBlock(style, title string, body *html.Node) *html.Node {
+	content := Element("div", "class", "content")
+	if title = strings.TrimSpace(title); title != "" {
+		content.AppendChild(AppendChildren(Element("p"), AppendChildren(Element("strong"), Text(title))))
+	}
+	MoveChildren(content, body)
+	return AppendChildren(Element("div", "class", "notice-block "+style), content)
+}
+
+// NoticeStyle returns the style of an Outline notice block built by Notices,
+// or "" if n is not one.
+func NoticeStyle(n *html.Node) string {
+	if !IsElement(n, "div") || !HasClass(n, "notice-block") {
+		return ""
+	}
+	for _, class := range strings.Fields(Attr(n, "class")) {
+		if class != "notice-block" {
+			return class
+		}
+	}
+	return "info"
+}
@@ -0,0 +1,54 @@
+package transform
+
+import "testing"
+
+func TestNotices(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "info macro with title",
+			body: `<div class="confluence-information-macro confluence-information-macro-information conf-macro output-block" data-macro-name="info">` +
+				`<p class="title conf-macro-render">Heads up</p><span class="aui-icon aui-icon-small aui-iconfont-info confluence-information-macro-icon"></span>` +
+				`<div class="confluence-information-macro-body"><p>Restart <em>both</em> nodes.</p></div></div>`,
+			want: `<div class="notice-block info"><div class="content"><p><strong>Heads up</strong></p><p>Restart <em>both</em> nodes.</p></div></div>`,
+		},
+		{
+			name: "note macro becomes warning",
+			body: `<div class="confluence-information-macro confluence-information-macro-note"><span class="confluence-information-macro-icon"></span>` +
+				`<div class="confluence-information-macro-body"><p>Careful</p></div></div>`,
+			want: `<div class="notice-block warning"><div class="content"><p>Careful</p></div></div>`,
+		},
+		{
+			name: "tip macro",
+			body: `<div class="confluence-information-macro confluence-information-macro-tip"><div class="confluence-information-macro-body">Tip</div></div>`,
+			want: `<div class="notice-block tip"><div class="content">Tip</div></div>`,
+		},
+		{
+			name: "custom panel with header",
+			body: `<div class="panel" data-macro-name="panel" style="border-width: 1px;"><div class="panelHeader"><b>Checklist</b></div>` +
+				`<div class="panelContent"><ul><li>one</li></ul></div></div>`,
+			want: `<div class="notice-block info"><div class="content"><p><strong>Checklist</strong></p><ul><li>one</li></ul></div></div>`,
+		},
+		{
+			name: "cloud success panel",
+			body: `<div class="panel" data-panel-type="success"><div class="panelContent"><p>Done</p></div></div>`,
+			want: `<div class="notice-block success"><div class="content"><p>Done</p></div></div>`,
+		},
+		{
+			name: "code panel is left alone",
+			body: `<div class="code panel"><pre>x</pre></div>`,
+			want: `<div class="code panel"><pre>x</pre></div>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, Notices{}); got != tt.want {
+				t.Errorf("Notices = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package transform
+
+import (
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// PublishedHeader puts the publish date and author of a blog post below its
+// title, since Outline keeps only when the migrated document was created.
+type PublishedHeader struct {
+	Published time.Time
+	// Author is the display name of the author, or "" to leave it out.
+	Author string
+}
+
+func (t PublishedHeader) Name() string {
+	return "published header"
+}
+
+func (t PublishedHeader) Transform(doc *html.Node) error {
+	text := "Published on " + t.Published.Format(time.DateOnly)
+	if t.Author != "" {
+		text += " by " + t.Author
+	}
+	header := AppendChildren(Element("p"), AppendChildren(Element("em"), Text(text)))
+
+	body := Body(doc)
+	if title := Find(body, ByTag("h1")); title != nil && title.Parent == body {
+		body.InsertBefore(header, title.NextSibling)
+		return nil
+	}
+	body.InsertBefore(header, body.FirstChild)
+	return nil
+}
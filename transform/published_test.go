@@ -0,0 +1,36 @@
+package transform
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishedHeader(t *testing.T) {
+	published := time.Date(2024, time.March, 5, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header PublishedHeader
+		body   string
+		want   string
+	}{
+		{
+			name:   "below the title",
+			header: PublishedHeader{Published: published, Author: "Jane Doe"},
+			body:   `<h1>Release notes</h1><p>Body</p>`,
+			want:   `<h1>Release notes</h1><p><em>Published on 2024-03-05 by Jane Doe</em></p><p>Body</p>`,
+		},
+		{
+			name:   "without title or author",
+			header: PublishedHeader{Published: published},
+			body:   `<p>Body</p>`,
+			want:   `<p><em>Published on 2024-03-05</em></p><p>Body</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, tt.header); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package transform
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// lozengeEmoji maps the colour classes of status macros to an emoji of the
+// same colour.
+var lozengeEmoji = map[string]string{
+	"aui-lozenge-success":  "🟢",
+	"aui-lozenge-error":    "🔴",
+	"aui-lozenge-current":  "🟡",
+	"aui-lozenge-complete": "🔵",
+	"aui-lozenge-progress": "🟣",
+}
+
+// StatusLozenges replaces status macros, which Outline has no coloured
+// lozenge for, with bold text prefixed with an emoji of the lozenge colour.
+type StatusLozenges struct{}
+
+func (t StatusLozenges) Name() string {
+	return "status lozenges"
+}
+
+func (t StatusLozenges) Transform(doc *html.Node) error {
+	for _, status := range FindAll(doc, or(ByMacro("status"), ByClass("status-macro"))) {
+		if status.Parent == nil {
+			continue
+		}
+		emoji := "⚪"
+		for class, e := range lozengeEmoji {
+			if HasClass(status, class) {
+				emoji = e
+				break
+			}
+		}
+		title := strings.TrimSpace(TextContent(status))
+		if title == "" {
+			Remove(status)
+			continue
+		}
+		ReplaceWith(status, AppendChildren(Element("strong"), Text(emoji+" "+title)))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package transform
+
+import "testing"
+
+func TestStatusLozenges(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "green lozenge",
+			body: `<p>State: <span class="status-macro aui-lozenge aui-lozenge-success conf-macro output-inline" data-macro-name="status">DONE</span></p>`,
+			want: `<p>State: <strong>🟢 DONE</strong></p>`,
+		},
+		{
+			name: "grey lozenge",
+			body: `<span class="status-macro aui-lozenge" data-macro-name="status">Draft</span>`,
+			want: `<strong>⚪ Draft</strong>`,
+		},
+		{
+			name: "empty lozenge is removed",
+			body: `<p>a<span class="status-macro aui-lozenge aui-lozenge-error"></span></p>`,
+			want: `<p>a</p>`,
+		},
+		{
+			name: "other lozenges are kept",
+			body: `<span class="aui-lozenge">Open</span>`,
+			want: `<span class="aui-lozenge">Open</span>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, StatusLozenges{}); got != tt.want {
+				t.Errorf("StatusLozenges = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
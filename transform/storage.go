@@ -0,0 +1,129 @@
+package transform
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// StorageMacros returns the outermost <ac:structured-macro> elements named
+// one of names in a page body in Confluence storage format, in document
+// order. Export view renders macros nested in another of the same kind as
+// part of their parent, so skipping them keeps the two in step.
+func StorageMacros(storage string, names ...string) ([]*html.Node, error) {
+	doc, err := parseStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+	isMacro := func(n *html.Node) bool {
+		if !IsElement(n, "ac:structured-macro") {
+			return false
+		}
+		for _, name := range names {
+			if Attr(n, "ac:name") == name {
+				return true
+			}
+		}
+		return false
+	}
+	var macros []*html.Node
+	for _, macro := range FindAll(doc, isMacro) {
+		if !hasAncestor(macro, isMacro) {
+			macros = append(macros, macro)
+		}
+	}
+	return macros, nil
+}
+
+// MacroParameter returns the value of the named <ac:parameter> of a storage
+// format macro, or "" if it is not set.
+func MacroParameter(macro *html.Node, name string) string {
+	for c := macro.FirstChild; c != nil; c = c.NextSibling {
+		if IsElement(c, "ac:parameter") && Attr(c, "ac:name") == name {
+			return strings.TrimSpace(TextContent(c))
+		}
+	}
+	return ""
+}
+
+// renderedMacros returns the outermost elements of doc that match, selected
+// before any of them is replaced. Macros rendered as part of an included
+// page are left out, as they are not in the storage format of the page.
+func renderedMacros(doc *html.Node, match func(*html.Node) bool) []*html.Node {
+	var macros []*html.Node
+	for _, macro := range FindAll(doc, match) {
+		if !hasAncestor(macro, match) && Attr(macro, includedAttr) == "" && !hasAncestor(macro, isIncluded) {
+			macros = append(macros, macro)
+		}
+	}
+	return macros
+}
+
+// isIncluded reports whether n holds the content of an included page: a
+// rendered include macro, or content Includes inlined.
+func isIncluded(n *html.Node) bool {
+	return n.Type == html.ElementNode && (Attr(n, includedAttr) != "" || ByMacro("include")(n) || ByMacro("excerpt-include")(n))
+}
+
+// pairMacros pairs the rendered macros of a page with the references parsed
+// from its storage format by position, as the export view does not name the
+// stored macro a rendered one comes from. matches reports whether the i-th
+// macro fits the i-th reference. No macro is paired when the counts differ,
+// as a macro Confluence renders without storing it would shift every later
+// pairing; otherwise pairing stops at the first macro that does not fit. It
+// returns how many macros, from the first, are paired.
+func pairMacros(macros []*html.Node, refs int, matches func(i int) bool, kind string, logger *slog.Logger) int {
+	if len(macros) != refs {
+		if len(macros) > 0 {
+			logger.Warn("Rendered "+kind+" macros do not match storage format, kept as exported", "rendered", len(macros), "storage", refs)
+		}
+		return 0
+	}
+	for i := range macros {
+		if !matches(i) {
+			logger.Warn("Rendered "+kind+" macro does not match storage format, it and the following are kept as exported", "index", i)
+			return i
+		}
+	}
+	return len(macros)
+}
+
+// hasAncestor reports whether an ancestor of n matches.
+func hasAncestor(n *html.Node, match func(*html.Node) bool) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// MacroBody returns the plain text body of a storage format macro, such as
+// the source of a code or diagram macro.
+func MacroBody(macro *html.Node) string {
+	body := Find(macro, ByTag("ac:plain-text-body"))
+	if body == nil {
+		return ""
+	}
+	return TextContent(body)
+}
+
+var (
+	cdataRegex       = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+	selfClosingRegex = regexp.MustCompile(`<((?:ac|ri|at):[\w-]+)([^<>]*?)\s*/>`)
+)
+
+// parseStorage parses a page body in storage format. The HTML parser does
+// not know CDATA sections, which storage format wraps macro bodies in, so
+// they are turned into escaped text first. It also ignores the slash of
+// self-closing tags other than void elements, so <ac:emoticon/> would
+// swallow the text after it; those tags get an explicit end tag.
+func parseStorage(storage string) (*html.Node, error) {
+	storage = cdataRegex.ReplaceAllStringFunc(storage, func(cdata string) string {
+		return html.EscapeString(cdataRegex.FindStringSubmatch(cdata)[1])
+	})
+	storage = selfClosingRegex.ReplaceAllString(storage, "<$1$2></$1>")
+	return Parse(storage)
+}
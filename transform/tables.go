@@ -0,0 +1,330 @@
+package transform
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Complex table modes of the Tables transformer.
+const (
+	TablesNormalize    = "normalize"
+	TablesPreformatted = "preformatted"
+	TablesImage        = "image"
+)
+
+// Tables handles tables Outline cannot represent: tables with merged cells
+// (rowspan or colspan) and tables nested in other tables. In
+// TablesNormalize mode merged cells are split, copying their content into
+// every cell they covered, nested tables are flattened into one paragraph
+// per row and the first row becomes the header. In TablesPreformatted mode
+// the table is replaced by a text grid in a <pre> block, and in TablesImage
+// mode by an SVG rendering of the table stored with Store. Every complex
+// table is passed to Report.
+type Tables struct {
+	Mode   string
+	Store  func(data []byte, filename string, contentType string) (string, error)
+	Report func(issue string)
+	Logger *slog.Logger
+}
+
+func (t Tables) Name() string {
+	return "tables"
+}
+
+// tableCell is a slot of a table grid. Slots covered by a merged cell point
+// at the cell that covers them.
+type tableCell struct {
+	node   *html.Node
+	origin bool
+}
+
+func (t Tables) Transform(doc *html.Node) error {
+	tables := FindAll(doc, ByTag("table"))
+	// Innermost tables first, so that a nested table is dealt with before the
+	// table holding it.
+	for i := len(tables) - 1; i >= 0; i-- {
+		table := tables[i]
+		if table.Parent == nil {
+			continue
+		}
+		merged := hasMergedCells(table)
+		nested := closestTable(table) != nil
+		if !merged && !nested {
+			continue
+		}
+		problem := "merged cells"
+		if nested {
+			problem = "nested table"
+		}
+
+		mode := t.Mode
+		if nested && mode == TablesNormalize {
+			ReplaceWith(table, flattenTable(table)...)
+			t.report(problem, "flattened into paragraphs")
+			continue
+		}
+		switch mode {
+		case TablesPreformatted:
+			ReplaceWith(table, AppendChildren(Element("pre"), AppendChildren(Element("code"), Text(tableText(tableGrid(table))))))
+			t.report(problem, "replaced by a preformatted block")
+		case TablesImage:
+			grid := tableGrid(table)
+			// Named by the table's position in the page, so images do not
+			// overwrite each other where attachments are stored by name.
+			url, err := t.Store([]byte(tableSVG(grid)), fmt.Sprintf("table-%d.svg", i+1), "image/svg+xml")
+			if err != nil {
+				t.Logger.Warn("Failed to store table image, using a preformatted block", "error", err)
+				ReplaceWith(table, AppendChildren(Element("pre"), AppendChildren(Element("code"), Text(tableText(grid)))))
+				t.report(problem, "replaced by a preformatted block")
+				continue
+			}
+			ReplaceWith(table, AppendChildren(Element("p"), Element("img", "src", url, "alt", "Table")))
+			t.report(problem, "replaced by an image")
+		default:
+			normalizeTable(table)
+			t.report(problem, "merged cells split and first row made the header")
+		}
+	}
+	return nil
+}
+
+func (t Tables) report(problem, handling string) {
+	if t.Report != nil {
+		t.Report(fmt.Sprintf("Table with %s %s", problem, handling))
+	}
+}
+
+// ownRows returns the rows of table, excluding rows of nested tables.
+func ownRows(table *html.Node) []*html.Node {
+	return FindAll(table, func(n *html.Node) bool {
+		return IsElement(n, "tr") && closestTable(n) == table
+	})
+}
+
+// rowCells returns the cells of row.
+func rowCells(row *html.Node) []*html.Node {
+	var cells []*html.Node
+	for c := row.FirstChild; c != nil; c = c.NextSibling {
+		if IsElement(c, "td") || IsElement(c, "th") {
+			cells = append(cells, c)
+		}
+	}
+	return cells
+}
+
+func hasMergedCells(table *html.Node) bool {
+	for _, row := range ownRows(table) {
+		for _, cell := range rowCells(row) {
+			if cellSpan(cell, "rowspan") > 1 || cellSpan(cell, "colspan") > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func cellSpan(cell *html.Node, attr string) int {
+	span, err := strconv.Atoi(Attr(cell, attr))
+	if err != nil || span < 1 {
+		return 1
+	}
+	return span
+}
+
+// tableGrid lays the cells of table out on a rectangular grid, the way a
+// browser does. Empty slots are nil.
+func tableGrid(table *html.Node) [][]tableCell {
+	rows := ownRows(table)
+	grid := make([][]tableCell, len(rows))
+	for r, row := range rows {
+		c := 0
+		for _, cell := range rowCells(row) {
+			for c < len(grid[r]) && grid[r][c].node != nil {
+				c++
+			}
+			rowspan, colspan := cellSpan(cell, "rowspan"), cellSpan(cell, "colspan")
+			for dr := 0; dr < rowspan && r+dr < len(rows); dr++ {
+				for dc := 0; dc < colspan; dc++ {
+					for len(grid[r+dr]) <= c+dc {
+						grid[r+dr] = append(grid[r+dr], tableCell{})
+					}
+					grid[r+dr][c+dc] = tableCell{node: cell, origin: dr == 0 && dc == 0}
+				}
+			}
+			c += colspan
+		}
+	}
+	width := 0
+	for _, row := range grid {
+		width = max(width, len(row))
+	}
+	for r := range grid {
+		for len(grid[r]) < width {
+			grid[r] = append(grid[r], tableCell{})
+		}
+	}
+	return grid
+}
+
+// normalizeTable splits merged cells, copying their content into every slot
+// they covered, and turns the first row into a header row.
+func normalizeTable(table *html.Node) {
+	rows := ownRows(table)
+	grid := tableGrid(table)
+	for r, row := range rows {
+		var cells []*html.Node
+		for _, slot := range grid[r] {
+			var cell *html.Node
+			switch {
+			case slot.node == nil:
+				cell = Element("td")
+			case slot.origin:
+				cell = slot.node
+			default:
+				cell = cloneNode(slot.node)
+			}
+			RemoveAttr(cell, "rowspan")
+			RemoveAttr(cell, "colspan")
+			cells = append(cells, cell)
+		}
+		for _, cell := range rowCells(row) {
+			Remove(cell)
+		}
+		AppendChildren(row, cells...)
+	}
+	if len(rows) > 0 {
+		for _, cell := range rowCells(rows[0]) {
+			cell.Data = "th"
+			cell.DataAtom = Element("th").DataAtom
+		}
+	}
+}
+
+// cloneNode returns a deep copy of n.
+func cloneNode(n *html.Node) *html.Node {
+	clone := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute(nil), n.Attr...),
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		clone.AppendChild(cloneNode(c))
+	}
+	return clone
+}
+
+// flattenTable returns one paragraph per row of table, with the cell texts
+// separated by " | ".
+func flattenTable(table *html.Node) []*html.Node {
+	var paragraphs []*html.Node
+	for _, row := range tableGrid(table) {
+		var texts []string
+		for _, slot := range row {
+			if slot.origin {
+				texts = append(texts, cellText(slot.node))
+			}
+		}
+		paragraphs = append(paragraphs, AppendChildren(Element("p"), Text(strings.Join(texts, " | "))))
+	}
+	return paragraphs
+}
+
+// cellText returns the text of a cell on a single line.
+func cellText(cell *html.Node) string {
+	return strings.Join(strings.Fields(TextContent(cell)), " ")
+}
+
+// gridTexts returns the text of every slot of grid. Slots covered by a
+// merged cell are empty.
+func gridTexts(grid [][]tableCell) ([][]string, []int) {
+	texts := make([][]string, len(grid))
+	var widths []int
+	for r, row := range grid {
+		texts[r] = make([]string, len(row))
+		for c, slot := range row {
+			if slot.origin {
+				texts[r][c] = cellText(slot.node)
+			}
+			for len(widths) <= c {
+				widths = append(widths, 0)
+			}
+			widths[c] = max(widths[c], utf8.RuneCountInString(texts[r][c]))
+		}
+	}
+	return texts, widths
+}
+
+// tableText renders grid as a text table, with a rule under the first row.
+func tableText(grid [][]tableCell) string {
+	texts, widths := gridTexts(grid)
+	var b strings.Builder
+	for r, row := range texts {
+		b.WriteString("|")
+		for c, text := range row {
+			b.WriteString(" " + text + strings.Repeat(" ", widths[c]-utf8.RuneCountInString(text)) + " |")
+		}
+		b.WriteString("\n")
+		if r == 0 && len(texts) > 1 {
+			b.WriteString("|")
+			for _, width := range widths {
+				b.WriteString(strings.Repeat("-", width+2) + "|")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Dimensions of the SVG table rendering, in pixels.
+const (
+	svgCharWidth = 7
+	svgRowHeight = 24
+	svgPadding   = 6
+)
+
+// tableSVG renders grid as an SVG image that keeps merged cells merged. The
+// first row is drawn bold.
+func tableSVG(grid [][]tableCell) string {
+	texts, widths := gridTexts(grid)
+	xs := []int{0}
+	for _, width := range widths {
+		xs = append(xs, xs[len(xs)-1]+width*svgCharWidth+2*svgPadding)
+	}
+	totalWidth, totalHeight := xs[len(xs)-1], len(grid)*svgRowHeight
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`, totalWidth+1, totalHeight+1)
+	for r, row := range grid {
+		for c, slot := range row {
+			if !slot.origin {
+				continue
+			}
+			// Find how far the merged cell reaches.
+			endC, endR := c+1, r+1
+			for endC < len(row) && row[endC].node == slot.node && !row[endC].origin {
+				endC++
+			}
+			for endR < len(grid) && grid[endR][c].node == slot.node && !grid[endR][c].origin {
+				endR++
+			}
+			x, y := xs[c], r*svgRowHeight
+			fill, weight := "#ffffff", "normal"
+			if r == 0 {
+				fill, weight = "#f4f5f7", "bold"
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="#c1c7d0"/>`,
+				x, y, xs[endC]-x, (endR-r)*svgRowHeight, fill)
+			fmt.Fprintf(&b, `<text x="%d" y="%d" font-weight="%s">%s</text>`,
+				x+svgPadding, y+svgRowHeight-8, weight, html.EscapeString(texts[r][c]))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
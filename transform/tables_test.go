@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+const mergedTable = `<table><tbody>` +
+	`<tr><td>Service</td><td colspan="2">Owners</td></tr>` +
+	`<tr><td rowspan="2">API</td><td>Ann</td><td>Bob</td></tr>` +
+	`<tr><td>Cy</td><td>Di</td></tr>` +
+	`</tbody></table>`
+
+func TestTablesNormalize(t *testing.T) {
+	var reported []string
+	tables := Tables{Mode: TablesNormalize, Report: func(issue string) { reported = append(reported, issue) }}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "merged cells are split",
+			body: mergedTable,
+			want: `<table><tbody>` +
+				`<tr><th>Service</th><th>Owners</th><th>Owners</th></tr>` +
+				`<tr><td>API</td><td>Ann</td><td>Bob</td></tr>` +
+				`<tr><td>API</td><td>Cy</td><td>Di</td></tr>` +
+				`</tbody></table>`,
+		},
+		{
+			name: "nested table is flattened",
+			body: `<table><tbody><tr><th>Env</th><th>Hosts</th></tr><tr><td>prod</td><td>` +
+				`<table><tbody><tr><td>web1</td><td>10.0.0.1</td></tr><tr><td>web2</td><td>10.0.0.2</td></tr></tbody></table>` +
+				`</td></tr></tbody></table>`,
+			want: `<table><tbody><tr><th>Env</th><th>Hosts</th></tr><tr><td>prod</td><td>` +
+				`<p>web1 | 10.0.0.1</p><p>web2 | 10.0.0.2</p>` +
+				`</td></tr></tbody></table>`,
+		},
+		{
+			name: "simple table is kept",
+			body: `<table><tbody><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr></tbody></table>`,
+			want: `<table><tbody><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr></tbody></table>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transformBody(t, tt.body, tables); got != tt.want {
+				t.Errorf("Tables = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if len(reported) != 2 {
+		t.Errorf("reported %v, want the two complex tables", reported)
+	}
+}
+
+func TestTablesPreformatted(t *testing.T) {
+	got := transformBody(t, mergedTable, Tables{Mode: TablesPreformatted})
+	want := "<pre><code>" +
+		"| Service | Owners |     |\n" +
+		"|---------|--------|-----|\n" +
+		"| API     | Ann    | Bob |\n" +
+		"|         | Cy     | Di  |" +
+		"</code></pre>"
+	if got != want {
+		t.Errorf("Tables = %q, want %q", got, want)
+	}
+}
+
+func TestTablesImage(t *testing.T) {
+	var stored string
+	tables := Tables{
+		Mode: TablesImage,
+		Store: func(data []byte, filename string, contentType string) (string, error) {
+			stored = string(data)
+			return "/stored/" + filename, nil
+		},
+	}
+	got := transformBody(t, mergedTable+mergedTable, tables)
+	if got != `<p><img src="/stored/table-1.svg" alt="Table"/></p><p><img src="/stored/table-2.svg" alt="Table"/></p>` {
+		t.Errorf("Tables = %q", got)
+	}
+	// Merged cells are drawn once, spanning the cells they cover.
+	if !strings.HasPrefix(stored, "<svg") || strings.Count(stored, "<rect") != 7 {
+		t.Errorf("unexpected SVG %s", stored)
+	}
+	if !strings.Contains(stored, `height="48"`) {
+		t.Errorf("row-spanning cell is not merged in SVG %s", stored)
+	}
+
+	tables.Store = func(data []byte, filename string, contentType string) (string, error) {
+		return "", errors.New("upload failed")
+	}
+	tables.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	if got := transformBody(t, mergedTable, tables); !strings.HasPrefix(got, "<pre><code>| Service") {
+		t.Errorf("failed upload did not fall back to preformatted: %q", got)
+	}
+}
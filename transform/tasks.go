@@ -0,0 +1,48 @@
+package transform
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Tasks replaces Confluence task lists with Outline checklists
+// (<ul class="checkbox_list"><li data-type="checkbox_item">), keeping the
+// checked state. User mentions and due dates inside a task become plain
+// text, since they would otherwise link to Confluence.
+type Tasks struct{}
+
+func (t Tasks) Name() string {
+	return "tasks"
+}
+
+func (t Tasks) Transform(doc *html.Node) error {
+	for _, list := range FindAll(doc, ByClass("inline-task-list")) {
+		list.Attr = []html.Attribute{{Key: "class", Val: "checkbox_list"}}
+		for li := list.FirstChild; li != nil; li = li.NextSibling {
+			if !IsElement(li, "li") {
+				continue
+			}
+			checked := HasClass(li, "checked")
+			li.Attr = []html.Attribute{{Key: "data-type", Val: "checkbox_item"}}
+			if checked {
+				SetAttr(li, "class", "checked")
+			}
+
+			for _, mention := range FindAll(li, or(ByClass("user-mention"), ByClass("confluence-userlink"))) {
+				name := strings.TrimPrefix(strings.TrimSpace(TextContent(mention)), "@")
+				ReplaceWith(mention, Text("@"+name))
+			}
+			for _, due := range FindAll(li, ByTag("time")) {
+				date := Attr(due, "datetime")
+				if date == "" {
+					date = strings.TrimSpace(TextContent(due))
+				}
+				ReplaceWith(due, Text("(due "+date+")"))
+			}
+
+			li.InsertBefore(Element("span", "class", "checkbox"), li.FirstChild)
+		}
+	}
+	return nil
+}
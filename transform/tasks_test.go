@@ -0,0 +1,16 @@
+package transform
+
+import "testing"
+
+func TestTasks(t *testing.T) {
+	body := `<ul class="inline-task-list" data-inline-tasks-content-id="9">` +
+		`<li class="checked" data-inline-task-id="1">Write runbook</li>` +
+		`<li data-inline-task-id="2"><span>Review <a class="confluence-userlink user-mention" data-username="bob" href="/display/~bob">@Bob Smith</a>` +
+		` by <time datetime="2026-11-01" class="date-future">01 Nov 2026</time></span></li></ul>`
+	want := `<ul class="checkbox_list">` +
+		`<li data-type="checkbox_item" class="checked"><span class="checkbox"></span>Write runbook</li>` +
+		`<li data-type="checkbox_item"><span class="checkbox"></span><span>Review @Bob Smith by (due 2026-11-01)</span></li></ul>`
+	if got := transformBody(t, body, Tasks{}); got != want {
+		t.Errorf("Tasks = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+// Package transform rewrites exported Confluence HTML into HTML that Outline
+// imports cleanly. Every rewrite is a Transformer operating on a parsed DOM;
+// a Pipeline runs them in order on a single in-memory document.
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Transformer is one step of a Pipeline. Transform mutates doc in place and
+// returns an error only when the document can no longer be processed;
+// problems with individual elements are logged and the element is left as
+// it was.
+type Transformer interface {
+	Name() string
+	Transform(doc *html.Node) error
+}
+
+// Pipeline runs its transformers in order.
+type Pipeline []Transformer
+
+func (p Pipeline) Run(doc *html.Node) error {
+	for _, t := range p {
+		if err := t.Transform(doc); err != nil {
+			return fmt.Errorf("%s transformer: %w", t.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Parse parses a complete HTML document.
+func Parse(htmlContent string) (*html.Node, error) {
+	return html.Parse(strings.NewReader(htmlContent))
+}
+
+// Render serialises doc back to HTML.
+func Render(doc *html.Node) (string, error) {
+	var b bytes.Buffer
+	if err := html.Render(&b, doc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Attr returns the value of attribute key on n, or "" if it is not set.
+func Attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// HasAttr reports whether n has attribute key.
+func HasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAttr sets attribute key on n, replacing an existing value.
+func SetAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// RemoveAttr removes attribute key from n.
+func RemoveAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+// HasClass reports whether the class attribute of n contains class.
+func HasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(Attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// IsElement reports whether n is an element with the given tag name.
+func IsElement(n *html.Node, tag string) bool {
+	return n != nil && n.Type == html.ElementNode && n.Data == tag
+}
+
+// FindAll returns every node below root (excluding root) that matches, in
+// document order. The result is collected before returning, so callers may
+// modify the tree while iterating over it.
+func FindAll(root *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if match(c) {
+				found = append(found, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return found
+}
+
+// Find returns the first node below root that matches, or nil.
+func Find(root *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if match(c) {
+			return c
+		}
+		if found := Find(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// ByTag matches elements with the given tag name.
+func ByTag(tag string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		return IsElement(n, tag)
+	}
+}
+
+// ByClass matches elements whose class attribute contains class.
+func ByClass(class string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		return n.Type == html.ElementNode && HasClass(n, class)
+	}
+}
+
+// TextContent returns the concatenated text of n and its descendants.
+func TextContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(TextContent(c))
+	}
+	return b.String()
+}
+
+// Element creates an element with the given tag and attributes, given as
+// alternating keys and values.
+func Element(tag string, attrs ...string) *html.Node {
+	n := &html.Node{
+		Type:     html.ElementNode,
+		Data:     tag,
+		DataAtom: atom.Lookup([]byte(tag)),
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.Attr = append(n.Attr, html.Attribute{Key: attrs[i], Val: attrs[i+1]})
+	}
+	return n
+}
+
+// Text creates a text node.
+func Text(s string) *html.Node {
+	return &html.Node{Type: html.TextNode, Data: s}
+}
+
+// AppendChildren appends children to parent and returns parent.
+func AppendChildren(parent *html.Node, children ...*html.Node) *html.Node {
+	for _, c := range children {
+		parent.AppendChild(c)
+	}
+	return parent
+}
+
+// MoveChildren moves all children of from to the end of to.
+func MoveChildren(to, from *html.Node) {
+	for c := from.FirstChild; c != nil; c = from.FirstChild {
+		from.RemoveChild(c)
+		to.AppendChild(c)
+	}
+}
+
+// ReplaceWith puts replacements where n is and detaches n.
+func ReplaceWith(n *html.Node, replacements ...*html.Node) {
+	parent := n.Parent
+	if parent == nil {
+		return
+	}
+	for _, r := range replacements {
+		if r.Parent != nil {
+			r.Parent.RemoveChild(r)
+		}
+		parent.InsertBefore(r, n)
+	}
+	parent.RemoveChild(n)
+}
+
+// Unwrap replaces n with its children.
+func Unwrap(n *html.Node) {
+	parent := n.Parent
+	if parent == nil {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		parent.InsertBefore(c, n)
+	}
+	parent.RemoveChild(n)
+}
+
+// Remove detaches n from its parent.
+func Remove(n *html.Node) {
+	if n.Parent != nil {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// Body returns the <body> element of doc, or doc itself for fragments.
+func Body(doc *html.Node) *html.Node {
+	if body := Find(doc, ByTag("body")); body != nil {
+		return body
+	}
+	return doc
+}
@@ -0,0 +1,90 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// transformBody parses body as the content of a page, runs transformers on
+// it and returns the rendered content of <body>.
+func transformBody(t *testing.T, body string, transformers ...Transformer) string {
+	t.Helper()
+	doc, err := Parse("<html><head></head><body>" + body + "</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Pipeline(transformers).Run(doc); err != nil {
+		t.Fatal(err)
+	}
+	return renderBody(t, doc)
+}
+
+func renderBody(t *testing.T, doc *html.Node) string {
+	t.Helper()
+	var b strings.Builder
+	for c := Body(doc).FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.String()
+}
+
+type recordingTransformer struct {
+	name string
+	log  *[]string
+	err  error
+}
+
+func (r recordingTransformer) Name() string {
+	return r.name
+}
+
+func (r recordingTransformer) Transform(doc *html.Node) error {
+	*r.log = append(*r.log, r.name)
+	return r.err
+}
+
+func TestPipelineRunsStepsInOrder(t *testing.T) {
+	var log []string
+	pipeline := Pipeline{
+		recordingTransformer{name: "first", log: &log},
+		recordingTransformer{name: "second", log: &log},
+	}
+	doc, _ := Parse("<p>x</p>")
+	if err := pipeline.Run(doc); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(log, ",") != "first,second" {
+		t.Errorf("steps ran as %v", log)
+	}
+}
+
+func TestPipelineStopsAtFirstError(t *testing.T) {
+	var log []string
+	boom := errors.New("boom")
+	pipeline := Pipeline{
+		recordingTransformer{name: "first", log: &log, err: boom},
+		recordingTransformer{name: "second", log: &log},
+	}
+	doc, _ := Parse("<p>x</p>")
+	err := pipeline.Run(doc)
+	if !errors.Is(err, boom) || !strings.Contains(err.Error(), "first") {
+		t.Errorf("Run() error = %v, want wrapped boom naming the step", err)
+	}
+	if len(log) != 1 {
+		t.Errorf("steps ran as %v, want only the first", log)
+	}
+}
+
+func TestUnwrapAndReplaceWith(t *testing.T) {
+	doc, _ := Parse(`<div id="outer"><span>a</span><b>b</b></div><i>old</i>`)
+	Unwrap(Find(doc, ByTag("div")))
+	ReplaceWith(Find(doc, ByTag("i")), Text("new"))
+	if got := renderBody(t, doc); got != "<span>a</span><b>b</b>new" {
+		t.Errorf("got %q", got)
+	}
+}